@@ -0,0 +1,497 @@
+package gofile
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheMode controls how a CachedFileSystem writes files which are put to it.
+type CacheMode int
+
+const (
+	// WriteThrough puts files to the backend and the cache before Put returns.
+	WriteThrough CacheMode = iota
+
+	// WriteBack puts files to the cache only, they are written to the backend when
+	// they are evicted or Flush is called.
+	WriteBack
+)
+
+// CacheOptions configures a CachedFileSystem, zero values disable the matching limit.
+type CacheOptions struct {
+	// MaxEntries is the most files the cache holds before the least recently used are evicted.
+	MaxEntries int
+
+	// MaxBytes is the most bytes the cache holds before the least recently used files are evicted.
+	MaxBytes int64
+
+	// TTL is how long a cached file is served before its ETag is revalidated against the backend,
+	// backends which report no ETag are revalidated by modification time and size.
+	TTL time.Duration
+
+	// Mode is how files put to the file system are written.
+	Mode CacheMode
+}
+
+// CacheStats counts the outcome of reads from a CachedFileSystem.
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Revalidations int64
+	Evictions     int64
+}
+
+// CachedFileSystem is a read through cache which keeps copies of the files in a slow
+// backend, for example s3, in a fast cache such as an OSFileSystem or MemoryFileSystem.
+//
+// Files are served from the cache until their TTL runs out, after which the ETag of
+// the backend file is checked and the file is downloaded again only if it has changed.
+// Files read from the cache return the FileInfo of the cached copy from Stat.
+type CachedFileSystem struct {
+	backend FileSystem
+	cache   FileSystem
+	opts    CacheOptions
+	time    Time
+
+	mu      sync.Mutex
+	loading map[string]chan struct{}
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+	stats   CacheStats
+}
+
+// cacheEntry records a file held in the cache of a CachedFileSystem.
+type cacheEntry struct {
+	path    string
+	size    int64
+	etag    string
+	modTime time.Time
+	expires time.Time
+
+	// dirty entries have been put to the cache but not yet written to the backend,
+	// opts are the options they were put with.
+	dirty bool
+	opts  PutOptions
+}
+
+// NewCachedFileSystem is a construct function which caches the files of backend in cache.
+func NewCachedFileSystem(backend, cache FileSystem, opts CacheOptions) *CachedFileSystem {
+	return &CachedFileSystem{
+		backend: backend,
+		cache:   cache,
+		opts:    opts,
+		time:    new(OSTime),
+		loading: make(map[string]chan struct{}),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the file from the cache if it holds a fresh copy, otherwise the file is
+// downloaded from the backend and cached. The lock is released while the backend is read
+// so other files are served meanwhile, concurrent reads of the same file wait for the
+// first to finish.
+func (c *CachedFileSystem) Get(path string) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wait(path)
+
+	done := make(chan struct{})
+	c.loading[path] = done
+	defer func() {
+		delete(c.loading, path)
+		close(done)
+	}()
+
+	if el, ok := c.entries[path]; ok {
+		entry := el.Value.(*cacheEntry)
+
+		fresh, err := c.revalidate(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if fresh {
+			file, err := c.cache.Get(path)
+			if err == nil {
+				c.lru.MoveToFront(el)
+				c.stats.Hits++
+				return c.newFile(file, path), nil
+			}
+			if !IsNotExist(err) || entry.dirty {
+				return nil, err
+			}
+		}
+
+		// the entry may have been evicted while the lock was released to revalidate it.
+		if el, ok := c.entries[path]; ok {
+			c.drop(el)
+		}
+	}
+
+	c.stats.Misses++
+
+	c.mu.Unlock()
+	file, info, cached, err := c.download(path)
+	c.mu.Lock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cached {
+		if err := c.add(newCacheEntry(path, info)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return c.newFile(file, path), nil
+}
+
+// download gets the file from the backend and copies it to the cache, cached is false if
+// the copy failed. The caller must not hold the lock.
+func (c *CachedFileSystem) download(path string) (file File, info FileInfo, cached bool, err error) {
+	file, err = c.backend.Get(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	info, err = Stat(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, false, err
+	}
+
+	// a file which cannot be cached is still returned, it is downloaded again next time.
+	if cachedFile, err := c.cache.Put(file, path); err == nil {
+		cachedFile.Close()
+		cached = true
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, false, err
+	}
+
+	return file, info, cached, nil
+}
+
+// wait blocks until no Get is reading path from the backend, releasing the lock while it
+// waits. The caller must hold the lock.
+func (c *CachedFileSystem) wait(path string) {
+	for {
+		done, ok := c.loading[path]
+		if !ok {
+			return
+		}
+
+		c.mu.Unlock()
+		<-done
+		c.mu.Lock()
+	}
+}
+
+// Put writes the file to the backend and cache in WriteThrough mode, or only to the
+// cache in WriteBack mode.
+func (c *CachedFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return c.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file like Put, passing the options to the backend.
+func (c *CachedFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wait(path)
+
+	if c.opts.Mode == WriteBack {
+		file, err := c.cache.Put(src, path)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := Stat(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if err := c.add(&cacheEntry{path: path, size: info.Size(), dirty: true, opts: opts}); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return c.newFile(file, path), nil
+	}
+
+	start, _ := src.Seek(0, io.SeekCurrent)
+
+	file, err := PutWithOptions(c.backend, src, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := Stat(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if el, ok := c.entries[path]; ok {
+		c.drop(el)
+	}
+
+	if _, err := src.Seek(start, io.SeekStart); err == nil {
+		if cached, err := c.cache.Put(src, path); err == nil {
+			cached.Close()
+			if err := c.add(newCacheEntry(path, info)); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return c.newFile(file, path), nil
+}
+
+// Delete removes the file from the cache and the backend. A file which has only been
+// put to the cache in WriteBack mode is not deleted from the backend.
+func (c *CachedFileSystem) Delete(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wait(path)
+
+	dirty := false
+	if el, ok := c.entries[path]; ok {
+		dirty = el.Value.(*cacheEntry).dirty
+		c.drop(el)
+	}
+
+	err := Delete(c.backend, path)
+	if dirty && IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// List returns the paths in the backend starting with prefix, along with the paths of
+// files waiting to be written back.
+func (c *CachedFileSystem) List(prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths, err := List(c.backend, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		seen[p] = true
+	}
+
+	for p, el := range c.entries {
+		if el.Value.(*cacheEntry).dirty && strings.HasPrefix(p, prefix) && !seen[p] {
+			paths = append(paths, p)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Flush writes every file put in WriteBack mode to the backend.
+func (c *CachedFileSystem) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		entry := el.Value.(*cacheEntry)
+		if !entry.dirty {
+			continue
+		}
+
+		if err := c.flush(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the number of hits, misses, revalidations and evictions so far.
+func (c *CachedFileSystem) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// revalidate reports whether the cached copy of entry can be served, checking its ETag, or
+// its modification time and size when the backend reports no ETag, against the backend
+// once its TTL has run out. The caller must hold the lock, which is
+// released while the backend is checked.
+func (c *CachedFileSystem) revalidate(entry *cacheEntry) (bool, error) {
+	if entry.dirty || c.opts.TTL == 0 || c.time.Now().Before(entry.expires) {
+		return true, nil
+	}
+
+	c.mu.Unlock()
+	info, err := StatPath(c.backend, entry.path)
+	c.mu.Lock()
+	if IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	c.stats.Revalidations++
+	if !entry.matches(info) {
+		return false, nil
+	}
+
+	entry.expires = c.time.Now().Add(c.opts.TTL)
+	return true, nil
+}
+
+// newCacheEntry returns an entry for a file whose backend copy has the given info.
+func newCacheEntry(path string, info FileInfo) *cacheEntry {
+	return &cacheEntry{
+		path:    path,
+		size:    info.Size(),
+		etag:    info.ETag(),
+		modTime: info.ModTime(),
+	}
+}
+
+// matches reports whether info describes the backend copy the entry was cached from,
+// comparing ETags when the entry has one and the modification time and size otherwise.
+func (e *cacheEntry) matches(info FileInfo) bool {
+	if e.etag != "" {
+		return info.ETag() == e.etag
+	}
+	if e.modTime.IsZero() {
+		return false
+	}
+
+	return info.ETag() == "" && info.ModTime().Equal(e.modTime) && info.Size() == e.size
+}
+
+// add records entry as the most recently used file and evicts files until the cache is
+// back within its limits. The caller must hold the lock.
+func (c *CachedFileSystem) add(entry *cacheEntry) error {
+	if el, ok := c.entries[entry.path]; ok {
+		c.remove(el)
+	}
+
+	if c.opts.TTL > 0 {
+		entry.expires = c.time.Now().Add(c.opts.TTL)
+	}
+
+	c.entries[entry.path] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.lru.Len() > 0 && c.full() {
+		el := c.lru.Back()
+		evicted := el.Value.(*cacheEntry)
+
+		if evicted.dirty {
+			if err := c.flush(evicted); err != nil {
+				return err
+			}
+		}
+
+		c.drop(el)
+		c.stats.Evictions++
+	}
+
+	return nil
+}
+
+// full reports whether the cache holds more than its limits allow.
+func (c *CachedFileSystem) full() bool {
+	return (c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries) ||
+		(c.opts.MaxBytes > 0 && c.size > c.opts.MaxBytes)
+}
+
+// flush writes a dirty entry to the backend. The caller must hold the lock.
+func (c *CachedFileSystem) flush(entry *cacheEntry) error {
+	cached, err := c.cache.Get(entry.path)
+	if err != nil {
+		return err
+	}
+	defer cached.Close()
+
+	file, err := PutWithOptions(c.backend, cached, entry.path, entry.opts)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := Stat(file)
+	if err != nil {
+		return err
+	}
+
+	entry.dirty = false
+	entry.etag = info.ETag()
+	entry.modTime = info.ModTime()
+	if c.opts.TTL > 0 {
+		entry.expires = c.time.Now().Add(c.opts.TTL)
+	}
+
+	return nil
+}
+
+// drop forgets an entry and removes its copy from the cache. The caller must hold the lock.
+func (c *CachedFileSystem) drop(el *list.Element) {
+	c.remove(el)
+	Delete(c.cache, el.Value.(*cacheEntry).path)
+}
+
+// remove forgets an entry. The caller must hold the lock.
+func (c *CachedFileSystem) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+
+	c.lru.Remove(el)
+	delete(c.entries, entry.path)
+	c.size -= entry.size
+}
+
+// newFile wraps a file so that writes to it go through the cached file system.
+func (c *CachedFileSystem) newFile(file File, path string) *CachedFile {
+	return &CachedFile{
+		file,
+		path,
+		c,
+	}
+}
+
+// CachedFile is a File read from a CachedFileSystem.
+type CachedFile struct {
+	File
+	path string
+	fs   *CachedFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *CachedFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the cached file system.
+func (f *CachedFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
@@ -0,0 +1,276 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpCachedFileSystem(opts CacheOptions) (*CachedFileSystem, *MemoryFileSystem, *MemoryFileSystem, *time.Time) {
+	backend := NewMemoryFileSystem()
+	cache := NewMemoryFileSystem()
+	cache.SetVersioning(false)
+
+	now := time.Unix(1000, 0)
+	timer := new(MockTime)
+	timer.On("Now").Return(func() time.Time { return now })
+
+	fs := NewCachedFileSystem(backend, cache, opts)
+	fs.time = timer
+
+	return fs, backend, cache, &now
+}
+
+func readAll(t *testing.T, fs FileSystem, path string) string {
+	file, err := fs.Get(path)
+	assert.Nil(t, err)
+	if err != nil {
+		return ""
+	}
+
+	b, _ := ioutil.ReadAll(file)
+	return string(b)
+}
+
+func TestCachedFileSystemServesRepeatedGetsFromCache(t *testing.T) {
+	fs, backend, cache, _ := setUpCachedFileSystem(CacheOptions{})
+	backend.Put(bytes.NewReader([]byte("template")), "email.html")
+
+	assert.Equal(t, "template", readAll(t, fs, "email.html"))
+	assert.Equal(t, "template", readAll(t, cache, "email.html"))
+
+	backend.Delete("email.html")
+
+	assert.Equal(t, "template", readAll(t, fs, "email.html"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, fs.Stats())
+}
+
+func TestCachedFileSystemRevalidatesETagAfterTTL(t *testing.T) {
+	fs, backend, _, now := setUpCachedFileSystem(CacheOptions{TTL: time.Minute})
+	backend.Put(bytes.NewReader([]byte("one")), "email.html")
+
+	assert.Equal(t, "one", readAll(t, fs, "email.html"))
+
+	*now = now.Add(2 * time.Minute)
+	assert.Equal(t, "one", readAll(t, fs, "email.html"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Revalidations: 1}, fs.Stats())
+
+	backend.Put(bytes.NewReader([]byte("two")), "email.html")
+	assert.Equal(t, "one", readAll(t, fs, "email.html"))
+
+	*now = now.Add(2 * time.Minute)
+	assert.Equal(t, "two", readAll(t, fs, "email.html"))
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Revalidations: 2}, fs.Stats())
+}
+
+func TestCachedFileSystemRevalidatesModTimeWithoutETag(t *testing.T) {
+	now := time.Unix(1000, 0)
+	timer := new(MockTime)
+	timer.On("Now").Return(func() time.Time { return now })
+
+	backend := &noETagFileSystem{NewMemoryFileSystem()}
+	backend.time = timer
+	backend.Put(bytes.NewReader([]byte("one")), "email.html")
+
+	fs := NewCachedFileSystem(backend, NewMemoryFileSystem(), CacheOptions{TTL: time.Minute})
+	fs.time = timer
+
+	assert.Equal(t, "one", readAll(t, fs, "email.html"))
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "one", readAll(t, fs, "email.html"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Revalidations: 1}, fs.Stats())
+
+	backend.Put(bytes.NewReader([]byte("two")), "email.html")
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, "two", readAll(t, fs, "email.html"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Revalidations: 2}, fs.Stats())
+}
+
+func TestCachedFileSystemEvictsLeastRecentlyUsed(t *testing.T) {
+	fs, backend, cache, _ := setUpCachedFileSystem(CacheOptions{MaxEntries: 2, MaxBytes: 10})
+	backend.Put(bytes.NewReader([]byte("aaa")), "a.txt")
+	backend.Put(bytes.NewReader([]byte("bbb")), "b.txt")
+	backend.Put(bytes.NewReader([]byte("ccc")), "c.txt")
+	backend.Put(bytes.NewReader([]byte("big file")), "d.txt")
+
+	readAll(t, fs, "a.txt")
+	readAll(t, fs, "b.txt")
+	readAll(t, fs, "a.txt")
+	readAll(t, fs, "c.txt")
+
+	paths, _ := cache.List("")
+	assert.Equal(t, []string{"a.txt", "c.txt"}, paths)
+
+	readAll(t, fs, "d.txt")
+
+	paths, _ = cache.List("")
+	assert.Equal(t, []string{"d.txt"}, paths)
+	assert.Equal(t, int64(3), fs.Stats().Evictions)
+}
+
+func TestCachedFileSystemWriteThroughPutsBoth(t *testing.T) {
+	fs, backend, cache, _ := setUpCachedFileSystem(CacheOptions{})
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, backend, "file.txt"))
+	assert.Equal(t, "contents", readAll(t, cache, "file.txt"))
+
+	assert.Equal(t, "contents", readAll(t, fs, "file.txt"))
+	assert.Equal(t, CacheStats{Hits: 1}, fs.Stats())
+
+	assert.Nil(t, fs.Delete("file.txt"))
+
+	_, err = backend.Get("file.txt")
+	assert.True(t, os.IsNotExist(err))
+	_, err = cache.Get("file.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCachedFileSystemWriteThroughCachesFromCurrentPosition(t *testing.T) {
+	fs, backend, cache, _ := setUpCachedFileSystem(CacheOptions{})
+
+	src := bytes.NewReader([]byte("header:contents"))
+	src.Seek(7, io.SeekStart)
+
+	_, err := fs.Put(src, "file.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, backend, "file.txt"))
+	assert.Equal(t, "contents", readAll(t, cache, "file.txt"))
+}
+
+func TestCachedFileSystemWriteBackDefersBackendUntilFlush(t *testing.T) {
+	fs, backend, _, _ := setUpCachedFileSystem(CacheOptions{Mode: WriteBack})
+
+	_, err := fs.PutWithOptions(bytes.NewReader([]byte("contents")), "file.txt", PutOptions{
+		Metadata: map[string]string{"owner": "me"},
+	})
+	assert.Nil(t, err)
+
+	_, err = backend.Get("file.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, "contents", readAll(t, fs, "file.txt"))
+
+	paths, _ := fs.List("")
+	assert.Equal(t, []string{"file.txt"}, paths)
+
+	assert.Nil(t, fs.Flush())
+
+	file, err := backend.Get("file.txt")
+	assert.Nil(t, err)
+	info, _ := Stat(file)
+	assert.Equal(t, map[string]string{"owner": "me"}, info.Metadata())
+}
+
+func TestCachedFileSystemWriteBackFlushesOnEviction(t *testing.T) {
+	fs, backend, _, _ := setUpCachedFileSystem(CacheOptions{Mode: WriteBack, MaxEntries: 1})
+
+	fs.Put(bytes.NewReader([]byte("one")), "one.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "two.txt")
+
+	assert.Equal(t, "one", readAll(t, backend, "one.txt"))
+
+	_, err := backend.Get("two.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCachedFileSystemServesHitsWhileMissIsDownloading(t *testing.T) {
+	fs, backend, _, _ := setUpCachedFileSystem(CacheOptions{})
+	backend.Put(bytes.NewReader([]byte("fast")), "fast.txt")
+	backend.Put(bytes.NewReader([]byte("slow")), "slow.txt")
+	assert.Equal(t, "fast", readAll(t, fs, "fast.txt"))
+
+	slow := &blockingFileSystem{backend, "slow.txt", make(chan struct{}), make(chan struct{})}
+	fs.backend = slow
+
+	done := make(chan string)
+	go func() {
+		done <- readAll(t, fs, "slow.txt")
+	}()
+	<-slow.started
+
+	hit := make(chan string)
+	go func() {
+		hit <- readAll(t, fs, "fast.txt")
+	}()
+
+	select {
+	case content := <-hit:
+		assert.Equal(t, "fast", content)
+	case <-time.After(time.Second):
+		t.Fatal("cache hit blocked by a download of another file")
+	}
+
+	close(slow.release)
+	assert.Equal(t, "slow", <-done)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, fs.Stats())
+}
+
+// blockingFileSystem blocks gets of path until release is closed.
+type blockingFileSystem struct {
+	FileSystem
+	path    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingFileSystem) Get(path string) (File, error) {
+	if path == b.path {
+		close(b.started)
+		<-b.release
+	}
+
+	return b.FileSystem.Get(path)
+}
+
+// noETagFileSystem reports no ETag for its files, like backends which have none.
+type noETagFileSystem struct {
+	*MemoryFileSystem
+}
+
+func (fs *noETagFileSystem) Get(path string) (File, error) {
+	file, err := fs.MemoryFileSystem.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &noETagFile{file}, nil
+}
+
+func (fs *noETagFileSystem) Stat(path string) (FileInfo, error) {
+	info, err := fs.MemoryFileSystem.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return noETagFileInfo{info}, nil
+}
+
+type noETagFile struct {
+	File
+}
+
+func (f *noETagFile) Stat() (os.FileInfo, error) {
+	info, err := Stat(f.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return noETagFileInfo{info}, nil
+}
+
+type noETagFileInfo struct {
+	FileInfo
+}
+
+func (noETagFileInfo) ETag() string { return "" }
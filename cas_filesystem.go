@@ -0,0 +1,330 @@
+package gofile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// casBlobPrefix is the directory blobs are stored under in the wrapped file system.
+	casBlobPrefix = "blobs/sha256/"
+
+	// casIndexPath is where the reference index is stored in the wrapped file system.
+	casIndexPath = "refs.json"
+)
+
+// CASFileSystem is a content addressable FileSystem. The contents of every file put are
+// stored once in the wrapped file system under their SHA-256 hash and names are kept as
+// references to that hash in an index, so putting identical content under many names
+// stores a single blob.
+//
+// Blobs are reference counted, a blob whose last reference is deleted or overwritten is
+// kept until GC is called so that it can be removed in one pass.
+//
+// The index is a single refs.json file which is read again before every Put, Delete and
+// GC, so instances sharing a backend see each other's changes. Writes are not atomic
+// across instances as file systems offer no compare and swap, so only one instance or
+// process may write at a time. Two writers racing can lose each other's references and
+// GC may then remove blobs which are still referenced.
+type CASFileSystem struct {
+	fs     FileSystem
+	mu     sync.Mutex
+	index  *casIndex
+	loaded bool
+}
+
+// casIndex is the reference index of a CASFileSystem as it is persisted.
+type casIndex struct {
+	// Refs maps each name to the hash of its contents.
+	Refs map[string]string `json:"refs"`
+
+	// Blobs holds the number of names referencing each stored blob.
+	Blobs map[string]int `json:"blobs"`
+}
+
+// NewCASFileSystem is a construct function which stores blobs and the reference index in fs.
+func NewCASFileSystem(fs FileSystem) *CASFileSystem {
+	return &CASFileSystem{
+		fs: fs,
+	}
+}
+
+// Put stores the contents of src under their hash, uploading them only if no identical
+// blob is already stored, and points name at the hash.
+func (c *CASFileSystem) Put(src io.ReadSeeker, name string) (File, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := c.index.Blobs[hash]; !ok {
+		if _, err := c.fs.Put(src, blobPath(hash)); err != nil {
+			return nil, err
+		}
+		c.index.Blobs[hash] = 0
+	}
+
+	if old, ok := c.index.Refs[name]; !ok || old != hash {
+		if ok {
+			c.index.Blobs[old]--
+		}
+		c.index.Refs[name] = hash
+		c.index.Blobs[hash]++
+	}
+
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+
+	return c.open(name, hash)
+}
+
+// Get returns the blob name references.
+func (c *CASFileSystem) Get(name string) (File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	hash, ok := c.index.Refs[name]
+	if !ok {
+		return nil, notExist("get", name)
+	}
+
+	return c.open(name, hash)
+}
+
+// Delete removes the reference name, the blob it pointed at is removed by the next GC
+// if nothing else references it.
+func (c *CASFileSystem) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reload(); err != nil {
+		return err
+	}
+
+	hash, ok := c.index.Refs[name]
+	if !ok {
+		return notExist("delete", name)
+	}
+
+	delete(c.index.Refs, name)
+	c.index.Blobs[hash]--
+
+	return c.save()
+}
+
+// List returns the sorted names starting with prefix.
+func (c *CASFileSystem) List(prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range c.index.Refs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Hash returns the SHA-256 hash of the contents name references.
+func (c *CASFileSystem) Hash(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return "", err
+	}
+
+	hash, ok := c.index.Refs[name]
+	if !ok {
+		return "", notExist("hash", name)
+	}
+
+	return hash, nil
+}
+
+// RefCount returns the number of names referencing the blob with the given hash.
+func (c *CASFileSystem) RefCount(hash string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return 0, err
+	}
+
+	return c.index.Blobs[hash], nil
+}
+
+// GC removes every blob no longer referenced by a name from the wrapped file system and
+// returns the number of blobs removed.
+func (c *CASFileSystem) GC() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reload(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for hash, refs := range c.index.Blobs {
+		if refs > 0 {
+			continue
+		}
+
+		if err := Delete(c.fs, blobPath(hash)); err != nil && !IsNotExist(err) {
+			c.save()
+			return removed, err
+		}
+
+		delete(c.index.Blobs, hash)
+		removed++
+	}
+
+	return removed, c.save()
+}
+
+// load reads the reference index from the wrapped file system the first time it is needed,
+// the caller must hold the lock.
+func (c *CASFileSystem) load() error {
+	if c.loaded {
+		return nil
+	}
+
+	index := &casIndex{
+		Refs:  make(map[string]string),
+		Blobs: make(map[string]int),
+	}
+
+	file, err := c.fs.Get(casIndexPath)
+	if err != nil && !IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		defer file.Close()
+
+		b, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(b, index); err != nil {
+			return err
+		}
+	}
+
+	c.index = index
+	c.loaded = true
+
+	return nil
+}
+
+// reload reads the reference index from the wrapped file system again, picking up changes
+// made by other instances, the caller must hold the lock.
+func (c *CASFileSystem) reload() error {
+	c.loaded = false
+	return c.load()
+}
+
+// save writes the reference index to the wrapped file system, the caller must hold the lock.
+func (c *CASFileSystem) save() error {
+	b, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.fs.Put(bytes.NewReader(b), casIndexPath)
+	return err
+}
+
+// open gets the blob with the given hash and presents it under name.
+func (c *CASFileSystem) open(name, hash string) (File, error) {
+	file, err := c.fs.Get(blobPath(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CASFile{
+		file,
+		name,
+		c,
+	}, nil
+}
+
+// blobPath returns the path a blob is stored at, blobs are spread over directories by
+// the first byte of their hash to keep directories small. Blobs are given a ".blob"
+// extension as file systems such as OSFileSystem only accept paths with an extension.
+func blobPath(hash string) string {
+	return casBlobPrefix + hash[:2] + "/" + hash + ".blob"
+}
+
+// CASFile is a File holding the blob a name in a CASFileSystem references.
+type CASFile struct {
+	File
+	name string
+	fs   *CASFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *CASFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p under the name of the file.
+func (f *CASFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.name)
+	return len(p), err
+}
+
+// Stat returns the info of the blob presented under the name of the file.
+func (f *CASFile) Stat() (os.FileInfo, error) {
+	info, err := Stat(f.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &renamedFileInfo{info, path.Base(f.name)}, nil
+}
+
+// renamedFileInfo overrides the name reported by a FileInfo, used by wrappers which
+// store files under a different path to the one they are requested by.
+type renamedFileInfo struct {
+	FileInfo
+	name string
+}
+
+// Name returns the base name the file is requested by.
+func (i *renamedFileInfo) Name() string {
+	return i.name
+}
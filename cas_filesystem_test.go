@@ -0,0 +1,129 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const helloHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestCASFileSystemDedupesIdenticalContent(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewCASFileSystem(mem)
+
+	_, err := fs.Put(bytes.NewReader([]byte("hello")), "a.txt")
+	assert.Nil(t, err)
+	_, err = fs.Put(bytes.NewReader([]byte("hello")), "b.txt")
+	assert.Nil(t, err)
+
+	versions, _ := mem.Versions(blobPath(helloHash))
+	assert.Len(t, versions, 1)
+
+	refs, _ := fs.RefCount(helloHash)
+	assert.Equal(t, 2, refs)
+
+	hash, _ := fs.Hash("b.txt")
+	assert.Equal(t, helloHash, hash)
+
+	file, err := fs.Get("b.txt")
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "hello", string(b))
+
+	info, _ := file.Stat()
+	assert.Equal(t, "b.txt", info.Name())
+}
+
+func TestCASFileSystemGCRemovesUnreferencedBlobs(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewCASFileSystem(mem)
+
+	fs.Put(bytes.NewReader([]byte("hello")), "a.txt")
+	fs.Put(bytes.NewReader([]byte("hello")), "b.txt")
+	fs.Put(bytes.NewReader([]byte("other")), "c.txt")
+
+	assert.Nil(t, fs.Delete("a.txt"))
+	fs.Put(bytes.NewReader([]byte("changed")), "c.txt")
+
+	removed, err := fs.GC()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = mem.Get(blobPath(helloHash))
+	assert.Nil(t, err)
+
+	assert.Nil(t, fs.Delete("b.txt"))
+	removed, _ = fs.GC()
+	assert.Equal(t, 1, removed)
+
+	_, err = mem.Get(blobPath(helloHash))
+	assert.True(t, IsNotExist(err))
+
+	_, err = fs.Get("a.txt")
+	assert.True(t, IsNotExist(err))
+}
+
+func TestCASFileSystemPersistsIndex(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	NewCASFileSystem(mem).Put(bytes.NewReader([]byte("hello")), "a.txt")
+
+	fs := NewCASFileSystem(mem)
+	file, err := fs.Get("a.txt")
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "hello", string(b))
+
+	refs, _ := fs.RefCount(helloHash)
+	assert.Equal(t, 1, refs)
+}
+
+func TestCASFileSystemStoresBlobsOnOSFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofile-cas")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := NewCASFileSystem(Sub(NewOSFileSystem(), dir))
+
+	_, err = fs.Put(bytes.NewReader([]byte("hello")), "a.txt")
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "blobs", "sha256", helloHash[:2], helloHash+".blob"))
+	assert.Nil(t, err)
+
+	file, err := fs.Get("a.txt")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "hello", string(b))
+}
+
+func TestCASFileSystemReloadsIndexBeforeWriting(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	a := NewCASFileSystem(mem)
+	b := NewCASFileSystem(mem)
+
+	a.Put(bytes.NewReader([]byte("hello")), "a.txt")
+	b.Put(bytes.NewReader([]byte("other")), "b.txt")
+	a.Put(bytes.NewReader([]byte("changed")), "a.txt")
+
+	removed, err := a.GC()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+
+	names, _ := NewCASFileSystem(mem).List("")
+	assert.Equal(t, []string{"a.txt", "b.txt"}, names)
+
+	file, err := b.Get("b.txt")
+	assert.Nil(t, err)
+
+	content, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "other", string(content))
+}
@@ -0,0 +1,72 @@
+package gofile
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when the contents of a file do not match the checksum stored with it.
+var ErrChecksumMismatch = errors.New("gofile: checksum mismatch")
+
+// ChecksumAlgorithm names the algorithm used to compute a checksum of the contents of a file.
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumNone disables checksums.
+	ChecksumNone ChecksumAlgorithm = ""
+
+	// ChecksumMD5 computes an md5 digest, sent to s3 as the Content-MD5 header.
+	ChecksumMD5 ChecksumAlgorithm = "MD5"
+
+	// ChecksumCRC32C computes a crc32 using the castagnoli polynomial, sent to s3 as x-amz-checksum-crc32c.
+	ChecksumCRC32C ChecksumAlgorithm = "CRC32C"
+
+	// ChecksumSHA256 computes a sha256 digest, sent to s3 as x-amz-checksum-sha256.
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+)
+
+// New returns a hash computing the checksum, nil is returned for ChecksumNone or an unknown algorithm.
+func (a ChecksumAlgorithm) New() hash.Hash {
+	switch a {
+	case ChecksumMD5:
+		return md5.New()
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+
+	return nil
+}
+
+// Sum returns the checksum of b, nil is returned for ChecksumNone.
+func (a ChecksumAlgorithm) Sum(b []byte) []byte {
+	h := a.New()
+	if h == nil {
+		return nil
+	}
+
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// formatChecksum encodes a checksum as it is stored alongside a file on disk.
+func formatChecksum(algo ChecksumAlgorithm, sum []byte) string {
+	return string(algo) + " " + hex.EncodeToString(sum) + "\n"
+}
+
+// parseChecksum decodes a checksum stored alongside a file on disk.
+func parseChecksum(s string) (ChecksumAlgorithm, []byte, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return ChecksumNone, nil, errors.New("gofile: malformed checksum")
+	}
+
+	sum, err := hex.DecodeString(parts[1])
+	return ChecksumAlgorithm(parts[0]), sum, err
+}
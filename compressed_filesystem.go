@@ -0,0 +1,462 @@
+package gofile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CodecMetadataKey is the metadata key the name of the codec a file was compressed with is stored under.
+	CodecMetadataKey = "Gofile-Codec"
+
+	// SizeMetadataKey is the metadata key the uncompressed size of a file is stored under.
+	SizeMetadataKey = "Gofile-Uncompressed-Size"
+)
+
+// errUnknownCodec is returned when the metadata of a file names a codec that is not known.
+var errUnknownCodec = errors.New("gofile: unknown compression codec")
+
+// Codec compresses and decompresses file contents for a CompressedFileSystem.
+type Codec interface {
+	// Name returns the name the codec is recorded under in metadata. Names registered
+	// as an http Content-Encoding, such as gzip, are also sent as the Content-Encoding.
+	Name() string
+
+	// Magic returns the bytes every stream written by the codec starts with.
+	Magic() []byte
+
+	// NewWriter returns a writer compressing to w, the stream is complete once it is closed.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	// Gzip is a Codec compressing with gzip.
+	Gzip Codec = gzipCodec{}
+
+	// Zstd is a Codec compressing with zstandard.
+	Zstd Codec = zstdCodec{}
+
+	// Snappy is a Codec compressing with the snappy framing format.
+	Snappy Codec = snappyCodec{}
+)
+
+// contentEncodings holds the codec names which are registered http Content-Encodings.
+var contentEncodings = map[string]bool{
+	"gzip": true,
+	"br":   true,
+	"zstd": true,
+}
+
+// codecs holds the built in codecs by name.
+var codecs = map[string]Codec{
+	Gzip.Name():   Gzip,
+	Zstd.Name():   Zstd,
+	Snappy.Name(): Snappy,
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string  { return "gzip" }
+func (gzipCodec) Magic() []byte { return []byte{0x1f, 0x8b} }
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string  { return "zstd" }
+func (zstdCodec) Magic() []byte { return []byte{0x28, 0xb5, 0x2f, 0xfd} }
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.IOReadCloser(), nil
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string  { return "snappy" }
+func (snappyCodec) Magic() []byte { return []byte("\xff\x06\x00\x00sNaPpY") }
+func (snappyCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(snappy.NewReader(r)), nil
+}
+
+// CompressionRule selects the Codec used for files matching either a path pattern or a mime type.
+type CompressionRule struct {
+	// Pattern is a path.Match pattern matched against both the full path and the base name.
+	Pattern string
+
+	// MIMEType is matched against the mime type of the path, a value ending in a slash
+	// such as text/ matches every subtype.
+	MIMEType string
+
+	// Codec is the codec used for matching files, nil stores them uncompressed.
+	Codec Codec
+}
+
+// matches reports whether the rule applies to a path.
+func (r CompressionRule) matches(p string) bool {
+	if r.Pattern != "" {
+		if ok, _ := path.Match(r.Pattern, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(r.Pattern, path.Base(p)); ok {
+			return true
+		}
+	}
+
+	if r.MIMEType != "" {
+		mimeType := strings.TrimSpace(strings.Split(GetMIMETypeFromPath(p), ";")[0])
+		if strings.HasSuffix(r.MIMEType, "/") {
+			return strings.HasPrefix(mimeType, r.MIMEType)
+		}
+
+		return mimeType == r.MIMEType
+	}
+
+	return false
+}
+
+// CompressedFileSystem wraps a FileSystem compressing files as they are put and
+// decompressing them as they are read. The codec for a file is chosen by the first
+// matching CompressionRule, files matching no rule are stored as is.
+//
+// The codec is recorded in the metadata of the file on backends which support it, and
+// in its Content-Encoding when the codec is a registered http encoding, so that stored
+// objects stay readable by other tools. On other backends the rules and the magic bytes
+// of the stored file are used to read it back.
+type CompressedFileSystem struct {
+	fs    FileSystem
+	rules []CompressionRule
+}
+
+// NewCompressedFileSystem is a construct function which wraps fs, compressing files according to rules.
+func NewCompressedFileSystem(fs FileSystem, rules ...CompressionRule) *CompressedFileSystem {
+	return &CompressedFileSystem{
+		fs,
+		rules,
+	}
+}
+
+// Put compresses the contents of src with the codec selected for path and puts them to the wrapped file system.
+func (c *CompressedFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return c.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions compresses the contents of src like Put, passing the options to the wrapped file system.
+func (c *CompressedFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	codec := c.codec(path)
+	if codec == nil {
+		return PutWithOptions(c.fs, src, path, opts)
+	}
+
+	buf := new(bytes.Buffer)
+	w, err := codec.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := io.Copy(w, src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if contentEncodings[codec.Name()] {
+		opts.ContentEncoding = codec.Name()
+	}
+	opts.Metadata = mergeMaps(opts.Metadata, map[string]string{
+		CodecMetadataKey: codec.Name(),
+		SizeMetadataKey:  strconv.FormatInt(size, 10),
+	})
+
+	file, err := PutWithOptions(c.fs, bytes.NewReader(buf.Bytes()), path, opts)
+	if err != nil {
+		return file, err
+	}
+
+	return c.newFile(file, path, codec, size), nil
+}
+
+// Get returns a File which decompresses the file at path as it is read.
+func (c *CompressedFileSystem) Get(path string) (File, error) {
+	file, err := c.fs.Get(path)
+	if err != nil {
+		return file, err
+	}
+
+	info, err := Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	codec := c.codec(path)
+	if name := metadataValue(info.Metadata(), CodecMetadataKey); name != "" && (codec == nil || codec.Name() != name) {
+		if codec = codecs[name]; codec == nil {
+			return nil, errUnknownCodec
+		}
+	}
+
+	if codec == nil || !hasMagic(file, codec) {
+		return file, nil
+	}
+
+	size := int64(-1)
+	if v := metadataValue(info.Metadata(), SizeMetadataKey); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	return c.newFile(file, path, codec, size), nil
+}
+
+// Delete removes the file at path from the wrapped file system.
+func (c *CompressedFileSystem) Delete(path string) error {
+	return Delete(c.fs, path)
+}
+
+// List returns the paths in the wrapped file system starting with prefix.
+func (c *CompressedFileSystem) List(prefix string) ([]string, error) {
+	return List(c.fs, prefix)
+}
+
+// codec returns the codec of the first rule matching path.
+func (c *CompressedFileSystem) codec(path string) Codec {
+	for _, rule := range c.rules {
+		if rule.matches(path) {
+			return rule.Codec
+		}
+	}
+
+	return nil
+}
+
+// newFile wraps a stored file in a CompressedFile.
+func (c *CompressedFileSystem) newFile(file File, path string, codec Codec, size int64) *CompressedFile {
+	return &CompressedFile{
+		file:  file,
+		path:  path,
+		fs:    c,
+		codec: codec,
+		size:  size,
+	}
+}
+
+// hasMagic reports whether a file starts with the magic bytes of a codec, leaving it
+// positioned at the start. Codecs without magic bytes are assumed to match.
+func hasMagic(file File, codec Codec) bool {
+	magic := codec.Magic()
+	if len(magic) == 0 {
+		return true
+	}
+
+	head := make([]byte, len(magic))
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	n, _ := io.ReadFull(file, head)
+	file.Seek(0, io.SeekStart)
+
+	return bytes.Equal(head[:n], magic)
+}
+
+// metadataValue looks up a metadata key case insensitively, as backends
+// which store metadata as http headers may change its case.
+func metadataValue(metadata map[string]string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// CompressedFile is a File which decompresses a file from the wrapped file system as it is read.
+// As compressed streams can only be read forwards, seeking backwards restarts decompression
+// from the start of the file.
+type CompressedFile struct {
+	file   File
+	path   string
+	fs     *CompressedFileSystem
+	codec  Codec
+	r      io.ReadCloser
+	offset int64
+	size   int64
+}
+
+// unwrap returns the wrapped file.
+func (f *CompressedFile) unwrap() File {
+	return f.file
+}
+
+// Read decompresses the next bytes of the file.
+func (f *CompressedFile) Read(p []byte) (n int, err error) {
+	if f.r == nil {
+		if err := f.reset(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = f.r.Read(p)
+	f.offset += int64(n)
+
+	return n, err
+}
+
+// Seek sets the offset of the next Read in the decompressed contents.
+func (f *CompressedFile) Seek(offset int64, whence int) (int64, error) {
+	var size int64
+	if whence == io.SeekEnd {
+		var err error
+		if size, err = f.length(); err != nil {
+			return f.offset, err
+		}
+	}
+
+	abs, err := seekOffset(f.offset, size, offset, whence)
+	if err != nil {
+		return f.offset, err
+	}
+
+	if abs < f.offset || f.r == nil {
+		if err := f.reset(); err != nil {
+			return f.offset, err
+		}
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, f, abs-f.offset); err != nil && err != io.EOF {
+		return f.offset, err
+	}
+
+	// Seeking past the end leaves the offset at the end of the contents.
+	return f.offset, nil
+}
+
+// Write replaces the contents of the file, compressing p and putting it to the wrapped file system.
+func (f *CompressedFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
+
+// Close closes the decompressor and the wrapped file.
+func (f *CompressedFile) Close() error {
+	if f.r != nil {
+		f.r.Close()
+	}
+
+	return f.file.Close()
+}
+
+// Stat returns the info of the wrapped file reporting the uncompressed size.
+func (f *CompressedFile) Stat() (os.FileInfo, error) {
+	info, err := Stat(f.file)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := f.length()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompressedFileInfo{
+		resizedFileInfo{info, size},
+		f.codec.Name(),
+	}, nil
+}
+
+// reset starts decompressing again from the start of the wrapped file.
+func (f *CompressedFile) reset() error {
+	if f.r != nil {
+		f.r.Close()
+		f.r = nil
+	}
+
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r, err := f.codec.NewReader(f.file)
+	if err != nil {
+		return err
+	}
+
+	f.r = r
+	f.offset = 0
+
+	return nil
+}
+
+// length returns the uncompressed size of the file, decompressing the whole file to count
+// it when the size was not recorded in metadata.
+func (f *CompressedFile) length() (int64, error) {
+	if f.size >= 0 {
+		return f.size, nil
+	}
+
+	offset := f.offset
+	if err := f.reset(); err != nil {
+		return 0, err
+	}
+
+	size, err := io.Copy(ioutil.Discard, f.r)
+	if err != nil {
+		return 0, err
+	}
+	f.size = size
+
+	if err := f.reset(); err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, f, offset); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// CompressedFileInfo is the FileInfo of a CompressedFile, Size reports the uncompressed
+// size of the file while StoredSize reports the size held by the wrapped file system.
+type CompressedFileInfo struct {
+	resizedFileInfo
+	codec string
+}
+
+// StoredSize returns the compressed size of the file.
+func (i *CompressedFileInfo) StoredSize() int64 {
+	return i.FileInfo.Size()
+}
+
+// Codec returns the name of the codec the file is compressed with.
+func (i *CompressedFileInfo) Codec() string {
+	return i.codec
+}
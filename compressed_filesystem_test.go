@@ -0,0 +1,142 @@
+package gofile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedFileSystemRoundTripsEachCodec(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"level":"info","msg":"request served"}`), 1000)
+
+	for _, codec := range []Codec{Gzip, Zstd, Snappy} {
+		mem := NewMemoryFileSystem()
+		fs := NewCompressedFileSystem(mem, CompressionRule{Pattern: "*.json", Codec: codec})
+
+		_, err := fs.Put(bytes.NewReader(content), "logs/today.json")
+		assert.Nil(t, err, codec.Name())
+
+		stored, _ := Stat(mustGet(mem, "logs/today.json"))
+		assert.True(t, stored.Size() < int64(len(content)), codec.Name())
+		assert.Equal(t, codec.Name(), stored.Metadata()[CodecMetadataKey])
+
+		file, err := fs.Get("logs/today.json")
+		assert.Nil(t, err, codec.Name())
+
+		b, _ := ioutil.ReadAll(file)
+		assert.Equal(t, content, b, codec.Name())
+
+		info, _ := file.Stat()
+		assert.Equal(t, int64(len(content)), info.Size())
+		assert.Equal(t, stored.Size(), info.(*CompressedFileInfo).StoredSize())
+	}
+}
+
+func TestCompressedFileSystemSelectsCodecByMIMEType(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewCompressedFileSystem(mem,
+		CompressionRule{MIMEType: "image/", Codec: nil},
+		CompressionRule{MIMEType: "text/", Codec: Gzip},
+	)
+
+	content := []byte("some text that is stored compressed")
+	fs.Put(bytes.NewReader(content), "notes.txt")
+	fs.Put(bytes.NewReader(content), "picture.png")
+
+	b, _ := ioutil.ReadAll(mustGet(mem, "notes.txt"))
+	assert.Equal(t, Gzip.Magic(), b[:2])
+
+	b, _ = ioutil.ReadAll(mustGet(mem, "picture.png"))
+	assert.Equal(t, content, b)
+}
+
+func TestCompressedFileSystemReadsByMagicWithoutMetadata(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewCompressedFileSystem(mem, CompressionRule{Pattern: "*.log", Codec: Gzip})
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	w.Write([]byte("compressed elsewhere"))
+	w.Close()
+	mem.Put(bytes.NewReader(buf.Bytes()), "app.log")
+	mem.Put(bytes.NewReader([]byte("stored before compression")), "old.log")
+
+	b, _ := ioutil.ReadAll(mustGet(fs, "app.log"))
+	assert.Equal(t, "compressed elsewhere", string(b))
+
+	info, _ := mustGet(fs, "app.log").Stat()
+	assert.Equal(t, int64(20), info.Size())
+
+	b, _ = ioutil.ReadAll(mustGet(fs, "old.log"))
+	assert.Equal(t, "stored before compression", string(b))
+}
+
+func TestCompressedFileSeeks(t *testing.T) {
+	fs := NewCompressedFileSystem(NewMemoryFileSystem(), CompressionRule{Pattern: "*", Codec: Zstd})
+	fs.Put(bytes.NewReader([]byte("0123456789")), "digits.txt")
+
+	file := mustGet(fs, "digits.txt")
+	file.Seek(6, io.SeekStart)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "6789", string(b))
+
+	file.Seek(2, io.SeekStart)
+	b = make([]byte, 3)
+	io.ReadFull(file, b)
+	assert.Equal(t, "234", string(b))
+
+	pos, _ := file.Seek(-1, io.SeekEnd)
+	assert.Equal(t, int64(9), pos)
+}
+
+func TestCompressedFileSeeksPastTheEnd(t *testing.T) {
+	fs := NewCompressedFileSystem(NewMemoryFileSystem(), CompressionRule{Pattern: "*", Codec: Gzip})
+	fs.Put(bytes.NewReader([]byte("0123456789")), "digits.txt")
+
+	file := mustGet(fs, "digits.txt")
+	pos, err := file.Seek(20, io.SeekStart)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), pos)
+
+	pos, _ = file.Seek(-4, io.SeekCurrent)
+	assert.Equal(t, int64(6), pos)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "6789", string(b))
+}
+
+func TestCompressedFileSystemOnlySendsRegisteredContentEncodings(t *testing.T) {
+	for codec, expected := range map[Codec]string{Gzip: "gzip", Zstd: "zstd", Snappy: ""} {
+		backend := &optionsRecordingFileSystem{FileSystem: NewMemoryFileSystem()}
+		fs := NewCompressedFileSystem(backend, CompressionRule{Pattern: "*", Codec: codec})
+
+		_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, backend.opts.ContentEncoding)
+		assert.Equal(t, codec.Name(), backend.opts.Metadata[CodecMetadataKey])
+	}
+}
+
+// optionsRecordingFileSystem records the options of the last PutWithOptions.
+type optionsRecordingFileSystem struct {
+	FileSystem
+	opts PutOptions
+}
+
+func (fs *optionsRecordingFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	fs.opts = opts
+	return fs.Put(src, path)
+}
+
+func mustGet(fs FileSystem, path string) File {
+	file, err := fs.Get(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return file
+}
@@ -0,0 +1,314 @@
+package gofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// detectLen is the number of bytes read from the start of a file to detect its type, enough
+// to find the names of the first entries of a zip based office document.
+const detectLen = 4096
+
+// signature matches files whose contents start with magic at offset.
+type signature struct {
+	offset      int
+	magic       string
+	contentType string
+}
+
+// signatures are checked before falling back to http.DetectContentType, they cover types
+// it does not recognise.
+var signatures = []signature{
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "8BPS", "image/vnd.adobe.photoshop"},
+	{0, "\xff\x0a", "image/jxl"},
+	{0, "\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a", "image/jxl"},
+	{0, "fLaC", "audio/flac"},
+	{0, "FLV\x01", "video/x-flv"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "application/x-ole-storage"},
+	{0, "{\\rtf", "application/rtf"},
+	{257, "ustar", "application/x-tar"},
+}
+
+// ftypBrands maps the major brand of an iso media file to its type, brands not listed
+// are left to http.DetectContentType.
+var ftypBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"avif": "image/avif",
+	"avis": "image/avif",
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"3gp4": "video/3gpp",
+	"3gp5": "video/3gpp",
+	"3g2a": "video/3gpp2",
+}
+
+// zipMimetypes match documents whose first zip entry is a file called mimetype stored
+// uncompressed, so that its name and contents follow the 30 byte local file header.
+var zipMimetypes = []signature{
+	{30, "mimetypeapplication/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.text"},
+	{30, "mimetypeapplication/vnd.oasis.opendocument.spreadsheet", "application/vnd.oasis.opendocument.spreadsheet"},
+	{30, "mimetypeapplication/vnd.oasis.opendocument.presentation", "application/vnd.oasis.opendocument.presentation"},
+	{30, "mimetypeapplication/epub+zip", "application/epub+zip"},
+}
+
+// zipEntries maps prefixes of the names of entries found at the start of a zip to the type
+// of document they make.
+var zipEntries = []signature{
+	{0, "word/", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{0, "xl/", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{0, "ppt/", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	{0, "META-INF/MANIFEST.MF", "application/java-archive"},
+}
+
+// DetectContentType detects the mime type of the contents of r from their first bytes and
+// rewinds r to where it started. It recognises images, pdf, zip and office documents,
+// archives, audio, video and fonts, falling back to http.DetectContentType which returns
+// "application/octet-stream" when the type is unknown.
+func DetectContentType(r io.ReadSeeker) (string, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	head := make([]byte, detectLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return detectContentType(head[:n]), nil
+}
+
+// detectContentType detects the mime type of contents starting with head.
+func detectContentType(head []byte) string {
+	for _, sig := range signatures {
+		if hasSignature(head, sig.offset, sig.magic) {
+			return sig.contentType
+		}
+	}
+
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		if t, ok := ftypBrands[string(head[8:12])]; ok {
+			return t
+		}
+	}
+
+	if hasSignature(head, 0, "\x1a\x45\xdf\xa3") && bytes.Contains(head, []byte("matroska")) {
+		return "video/x-matroska"
+	}
+
+	if hasSignature(head, 0, "PK\x03\x04") {
+		return detectZip(head)
+	}
+
+	detected := http.DetectContentType(head)
+	if strings.HasPrefix(detected, "text/") && isSVG(head) {
+		return "image/svg+xml"
+	}
+
+	return detected
+}
+
+// detectZip detects the type of document held by a zip starting with head from the names
+// of its first entries, returning application/zip if they are not those of a known document.
+func detectZip(head []byte) string {
+	for _, sig := range zipMimetypes {
+		if hasSignature(head, sig.offset, sig.magic) {
+			return sig.contentType
+		}
+	}
+
+	for _, name := range zipEntryNames(head) {
+		for _, entry := range zipEntries {
+			if strings.HasPrefix(name, entry.magic) {
+				return entry.contentType
+			}
+		}
+	}
+
+	return "application/zip"
+}
+
+// zipEntryNames returns the names of the entries whose local file headers lie in head. The
+// headers are followed from one entry to the next until head runs out or an entry which
+// records its size after its data, leaving the next header unknown.
+func zipEntryNames(head []byte) []string {
+	var names []string
+	for offset := 0; hasSignature(head, offset, "PK\x03\x04") && offset+30 <= len(head); {
+		flags := binary.LittleEndian.Uint16(head[offset+6:])
+		size := int(binary.LittleEndian.Uint32(head[offset+18:]))
+		nameLen := int(binary.LittleEndian.Uint16(head[offset+26:]))
+		extraLen := int(binary.LittleEndian.Uint16(head[offset+28:]))
+
+		start := offset + 30
+		if start+nameLen > len(head) {
+			break
+		}
+		names = append(names, string(head[start:start+nameLen]))
+
+		if flags&0x8 != 0 {
+			break
+		}
+		offset = start + nameLen + extraLen + size
+	}
+
+	return names
+}
+
+// hasSignature reports whether head holds magic at offset.
+func hasSignature(head []byte, offset int, magic string) bool {
+	return len(head) >= offset+len(magic) && string(head[offset:offset+len(magic)]) == magic
+}
+
+// isSVG reports whether text contents are an svg document.
+func isSVG(head []byte) bool {
+	text := bytes.ToLower(bytes.TrimSpace(head))
+	if bytes.HasPrefix(text, []byte("<?xml")) || bytes.HasPrefix(text, []byte("<!--")) ||
+		bytes.HasPrefix(text, []byte("<!doctype svg")) {
+		return bytes.Contains(text, []byte("<svg"))
+	}
+
+	return bytes.HasPrefix(text, []byte("<svg"))
+}
+
+// extensionTypes maps extensions to the type DetectContentType reports for files using
+// them. Unlike mime.TypeByExtension it does not read the mime.types files of the host, so
+// contents are checked against their extension the same way on every machine.
+var extensionTypes = map[string]string{
+	".avif":  "image/avif",
+	".bmp":   "image/bmp",
+	".gif":   "image/gif",
+	".heic":  "image/heic",
+	".heif":  "image/heif",
+	".ico":   "image/x-icon",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".jxl":   "image/jxl",
+	".png":   "image/png",
+	".psd":   "image/vnd.adobe.photoshop",
+	".svg":   "image/svg+xml",
+	".tif":   "image/tiff",
+	".tiff":  "image/tiff",
+	".webp":  "image/webp",
+	".aif":   "audio/aiff",
+	".aiff":  "audio/aiff",
+	".flac":  "audio/flac",
+	".m4a":   "audio/mp4",
+	".mid":   "audio/midi",
+	".midi":  "audio/midi",
+	".mp3":   "audio/mpeg",
+	".wav":   "audio/wave",
+	".3g2":   "video/3gpp2",
+	".3gp":   "video/3gpp",
+	".avi":   "video/avi",
+	".flv":   "video/x-flv",
+	".mkv":   "video/x-matroska",
+	".mov":   "video/quicktime",
+	".mp4":   "video/mp4",
+	".eot":   "application/vnd.ms-fontobject",
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".7z":    "application/x-7z-compressed",
+	".bz2":   "application/x-bzip2",
+	".gz":    "application/x-gzip",
+	".jar":   "application/java-archive",
+	".tar":   "application/x-tar",
+	".xz":    "application/x-xz",
+	".zip":   "application/zip",
+	".zst":   "application/zstd",
+	".doc":   "application/x-ole-storage",
+	".ppt":   "application/x-ole-storage",
+	".xls":   "application/x-ole-storage",
+	".docx":  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".odp":   "application/vnd.oasis.opendocument.presentation",
+	".ods":   "application/vnd.oasis.opendocument.spreadsheet",
+	".odt":   "application/vnd.oasis.opendocument.text",
+	".epub":  "application/epub+zip",
+	".pdf":   "application/pdf",
+	".rtf":   "application/rtf",
+	".wasm":  "application/wasm",
+	".json":  "application/json",
+	".css":   "text/css",
+	".csv":   "text/csv",
+	".htm":   "text/html",
+	".html":  "text/html",
+	".js":    "text/javascript",
+	".md":    "text/markdown",
+	".txt":   "text/plain",
+	".xml":   "text/xml",
+}
+
+// ambiguousExtensions are extensions shared by several types, or which say nothing about
+// the contents, so the contents of files using them are sniffed.
+var ambiguousExtensions = map[string]bool{
+	".bin":  true,
+	".dat":  true,
+	".tmp":  true,
+	".ogg":  true,
+	".webm": true,
+	".ts":   true,
+}
+
+// contentTypeFor returns the mime type of a file at path, from the extension when it
+// identifies the type and otherwise by detecting it from the contents of r.
+func contentTypeFor(name string, r io.ReadSeeker) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext != "" && !ambiguousExtensions[ext] {
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+	}
+
+	detected, err := DetectContentType(r)
+	if err != nil {
+		return GetMIMETypeFromPath(name)
+	}
+
+	return detected
+}
+
+// extensionForType returns the file extension for a mime type, e.g. ".gif", or an empty
+// string if the type has none.
+func extensionForType(t string) string {
+	t = mediaType(t)
+	if t == "text/plain" {
+		return ".txt"
+	}
+
+	exts, err := mime.ExtensionsByType(t)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+
+	// prefer the extension a media type is named after, image/jpeg has .jpe listed first.
+	for _, ext := range exts {
+		if strings.HasSuffix(t, ext[1:]) {
+			return ext
+		}
+	}
+
+	return exts[0]
+}
@@ -0,0 +1,91 @@
+package gofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectContentTypeRecognisesMagicBytes(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	cases := map[string]string{
+		"image/png":                   string(pngHeader),
+		"image/tiff":                  "II*\x00\x08\x00\x00\x00",
+		"image/heic":                  "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00",
+		"image/avif":                  "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00",
+		"image/svg+xml":               "<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>",
+		"application/pdf":             "%PDF-1.7\n",
+		"audio/flac":                  "fLaC\x00\x00\x00\x22",
+		"audio/mp4":                   "\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00",
+		"video/quicktime":             "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00",
+		"video/x-matroska":            "\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska",
+		"font/woff2":                  "wOF2\x00\x01\x00\x00",
+		"application/zip":             "PK\x03\x04\x14\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00\x00a.txt",
+		"application/x-tar":           string(tar),
+		"application/x-7z-compressed": "7z\xbc\xaf\x27\x1c\x00\x04",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": zipEntry("[Content_Types].xml", "<Types/>") + zipEntry("word/document.xml", ""),
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       zipEntry("xl/workbook.xml", ""),
+		"application/epub+zip": zipEntry("mimetype", "application/epub+zip"),
+	}
+
+	for expected, content := range cases {
+		detected, err := DetectContentType(strings.NewReader(content))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, detected, expected)
+	}
+}
+
+func TestDetectContentTypeRewindsReader(t *testing.T) {
+	content := append([]byte("prefix"), pngHeader...)
+	r := bytes.NewReader(content)
+	r.Seek(6, io.SeekStart)
+
+	detected, err := DetectContentType(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", detected)
+
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	assert.Equal(t, int64(6), pos)
+}
+
+func TestDetectContentTypeFallsBackToOctetStream(t *testing.T) {
+	detected, err := DetectContentType(bytes.NewReader([]byte{0x00, 0x01, 0x02, 0x03}))
+	assert.Nil(t, err)
+	assert.Equal(t, "application/octet-stream", detected)
+}
+
+func TestContentTypeForSniffsMissingOrAmbiguousExtensions(t *testing.T) {
+	assert.Equal(t, "image/png", contentTypeFor("avatars/123", bytes.NewReader(pngHeader)))
+	assert.Equal(t, "image/png", contentTypeFor("upload.bin", bytes.NewReader(pngHeader)))
+	assert.Equal(t, "image/jpeg", contentTypeFor("photo.jpg", bytes.NewReader(pngHeader)))
+}
+
+func TestDetectContentTypeOnlyMatchesZipEntryNames(t *testing.T) {
+	cases := []string{
+		zipEntry("notes.txt", "see word/document.xml"),
+		zipEntry("readme/word/", "") + zipEntry("data/xl/sheet", ""),
+	}
+
+	for _, content := range cases {
+		detected, err := DetectContentType(strings.NewReader(content))
+		assert.Nil(t, err)
+		assert.Equal(t, "application/zip", detected)
+	}
+}
+
+// zipEntry returns the local file header, name and contents of a stored zip entry.
+func zipEntry(name, contents string) string {
+	header := make([]byte, 30)
+	copy(header, "PK\x03\x04")
+	binary.LittleEndian.PutUint32(header[18:], uint32(len(contents)))
+	binary.LittleEndian.PutUint32(header[22:], uint32(len(contents)))
+	binary.LittleEndian.PutUint16(header[26:], uint16(len(name)))
+
+	return string(header) + name + contents
+}
@@ -0,0 +1,280 @@
+package gofile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidDataURI is returned when a data uri is malformed.
+var ErrInvalidDataURI = errors.New("gofile: invalid data uri")
+
+// maxDataURIHeader is the longest data uri header NewBase64Decoder reads looking for the
+// comma which ends it, so that a stream without one is not buffered in memory.
+const maxDataURIHeader = 4096
+
+// defaultDataURIType is the media type of a data uri which does not give one, see RFC 2397.
+const defaultDataURIType = "text/plain"
+
+// DataURI is a data uri as described by RFC 2397, e.g. data:image/gif;base64,R0lGOD...
+type DataURI struct {
+	// MediaType is the lower cased media type of the data, text/plain when the uri gives none.
+	MediaType string
+
+	// Params holds the parameters of the media type such as charset, keyed by lower cased name.
+	Params map[string]string
+
+	// Base64 reports whether the data is base64 encoded rather than percent encoded.
+	Base64 bool
+
+	data     []byte
+	encoding *base64.Encoding
+}
+
+// ParseDataURI parses a data uri. Both base64 and percent encoded data are supported, base64
+// data may use the standard or url safe alphabet with or without padding. The data is checked
+// as it is parsed so that malformed input returns ErrInvalidDataURI rather than empty contents.
+func ParseDataURI(src []byte) (*DataURI, error) {
+	src = bytes.TrimSpace(src)
+	if len(src) < 5 || !strings.EqualFold(string(src[:5]), "data:") {
+		return nil, ErrInvalidDataURI
+	}
+
+	comma := bytes.IndexByte(src, ',')
+	if comma < 0 {
+		return nil, ErrInvalidDataURI
+	}
+
+	d := &DataURI{
+		MediaType: defaultDataURIType,
+		Params:    make(map[string]string),
+	}
+
+	parts := strings.Split(string(src[5:comma]), ";")
+	if last := len(parts) - 1; last > 0 && strings.EqualFold(parts[last], "base64") {
+		d.Base64 = true
+		parts = parts[:last]
+	}
+
+	if t := strings.TrimSpace(parts[0]); t != "" {
+		slash := strings.IndexByte(t, '/')
+		if slash <= 0 || slash == len(t)-1 {
+			return nil, ErrInvalidDataURI
+		}
+		d.MediaType = strings.ToLower(t)
+	}
+
+	for _, param := range parts[1:] {
+		eq := strings.IndexByte(param, '=')
+		if eq <= 0 {
+			return nil, ErrInvalidDataURI
+		}
+
+		value, err := url.PathUnescape(param[eq+1:])
+		if err != nil {
+			return nil, ErrInvalidDataURI
+		}
+		d.Params[strings.ToLower(strings.TrimSpace(param[:eq]))] = value
+	}
+
+	if _, ok := d.Params["charset"]; !ok && parts[0] == "" {
+		d.Params["charset"] = "US-ASCII"
+	}
+
+	data := src[comma+1:]
+	if !d.Base64 {
+		decoded, err := url.PathUnescape(string(data))
+		if err != nil {
+			return nil, ErrInvalidDataURI
+		}
+		d.data = []byte(decoded)
+
+		return d, nil
+	}
+
+	encoding, payload, err := base64Encoding(data)
+	if err != nil {
+		return nil, err
+	}
+	d.encoding, d.data = encoding, payload
+
+	return d, nil
+}
+
+// base64Encoding returns the encoding of base64 data with whitespace and percent encoded
+// padding removed, failing if the data holds characters outside of either alphabet.
+func base64Encoding(data []byte) (*base64.Encoding, []byte, error) {
+	data = bytes.Replace(data, []byte("%3D"), []byte("="), -1)
+	data = bytes.Replace(data, []byte("%3d"), []byte("="), -1)
+
+	payload := make([]byte, 0, len(data))
+	urlSafe, std := false, false
+	for _, c := range data {
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case c == '-' || c == '_':
+			urlSafe = true
+		case c == '+' || c == '/':
+			std = true
+		case c == '=', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		default:
+			return nil, nil, ErrInvalidDataURI
+		}
+
+		payload = append(payload, c)
+	}
+
+	if urlSafe && std {
+		return nil, nil, ErrInvalidDataURI
+	}
+
+	unpadded := bytes.TrimRight(payload, "=")
+	if bytes.IndexByte(unpadded, '=') >= 0 || len(unpadded)%4 == 1 {
+		return nil, nil, ErrInvalidDataURI
+	}
+
+	encoding := base64.StdEncoding
+	if urlSafe {
+		encoding = base64.URLEncoding
+	}
+
+	return encoding.WithPadding(base64.NoPadding), unpadded, nil
+}
+
+// Reader returns a reader which decodes the data as it is read.
+func (d *DataURI) Reader() io.Reader {
+	if !d.Base64 {
+		return bytes.NewReader(d.data)
+	}
+
+	return base64.NewDecoder(d.encoding, bytes.NewReader(d.data))
+}
+
+// Bytes returns the decoded data.
+func (d *DataURI) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, d.Reader()); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Extension returns the file extension for the media type, e.g. ".gif", or an empty string
+// if the type has none.
+func (d *DataURI) Extension() string {
+	return extensionForType(d.MediaType)
+}
+
+// ContentType returns the media type with its parameters, as it would appear in a header.
+func (d *DataURI) ContentType() string {
+	return mime.FormatMediaType(d.MediaType, d.Params)
+}
+
+// NewBase64Decoder returns a reader which decodes base64 from r as it is read, so that large
+// payloads are never held in memory. A leading data uri header such as data:image/png;base64,
+// is skipped, whitespace is ignored and the standard and url safe alphabets are accepted with
+// or without padding. Malformed data fails the read with a base64.CorruptInputError, and a
+// header which is not for base64 data or is longer than 4KB with ErrInvalidDataURI.
+func NewBase64Decoder(r io.Reader) io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, &base64Normalizer{src: bufio.NewReader(r)})
+}
+
+// base64Normalizer rewrites a base64 stream to the padded standard alphabet.
+type base64Normalizer struct {
+	src     *bufio.Reader
+	started bool
+	eof     bool
+	count   int
+	pad     int
+}
+
+// Read copies the normalised base64 to p, padding the data once the source is exhausted.
+func (r *base64Normalizer) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		if err := r.skipHeader(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for n < len(p) {
+		if r.pad > 0 {
+			p[n] = '='
+			n++
+			r.pad--
+			continue
+		}
+		if r.eof {
+			break
+		}
+
+		c, err := r.src.ReadByte()
+		if err == io.EOF {
+			r.eof = true
+			if rem := r.count % 4; rem != 0 {
+				r.pad = 4 - rem
+			}
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '-':
+			c = '+'
+		case '_':
+			c = '/'
+		}
+
+		p[n] = c
+		n++
+		r.count++
+	}
+
+	if n == 0 && r.eof {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// skipHeader reads past a data uri header at the start of the source.
+func (r *base64Normalizer) skipHeader() error {
+	prefix, _ := r.src.Peek(5)
+	if !strings.EqualFold(string(prefix), "data:") {
+		return nil
+	}
+
+	var header strings.Builder
+	for {
+		c, err := r.src.ReadByte()
+		if err != nil {
+			return ErrInvalidDataURI
+		}
+
+		header.WriteByte(c)
+		if c == ',' {
+			break
+		}
+		if header.Len() >= maxDataURIHeader {
+			return ErrInvalidDataURI
+		}
+	}
+
+	if !strings.HasSuffix(strings.ToLower(header.String()), ";base64,") {
+		return ErrInvalidDataURI
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package gofile
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDataURIDecodesBase64(t *testing.T) {
+	uri, err := ParseDataURI([]byte("data:image/gif;base64,R0lGODlhAQABAIAAAP///////yH5BAEKAAEALAAAAAABAAEAAAICTAEAOw=="))
+	assert.Nil(t, err)
+	assert.Equal(t, "image/gif", uri.MediaType)
+	assert.True(t, uri.Base64)
+	assert.Equal(t, ".gif", uri.Extension())
+
+	b, err := ioutil.ReadAll(uri.Reader())
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("GIF89a"), b[:6])
+	assert.Len(t, b, 43)
+}
+
+func TestParseDataURISupportsNonImageTypesAndParams(t *testing.T) {
+	uri, err := ParseDataURI([]byte("data:application/json;charset=utf-8;base64,eyJhIjoxfQ=="))
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", uri.MediaType)
+	assert.Equal(t, map[string]string{"charset": "utf-8"}, uri.Params)
+	assert.Equal(t, "application/json; charset=utf-8", uri.ContentType())
+
+	b, err := uri.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, string(b))
+}
+
+func TestParseDataURIDecodesPercentEncodedData(t *testing.T) {
+	uri, err := ParseDataURI([]byte("data:,A%20brief%20note"))
+	assert.Nil(t, err)
+	assert.Equal(t, "text/plain", uri.MediaType)
+	assert.Equal(t, "US-ASCII", uri.Params["charset"])
+	assert.False(t, uri.Base64)
+	assert.Equal(t, ".txt", uri.Extension())
+
+	b, err := uri.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "A brief note", string(b))
+}
+
+func TestParseDataURIDecodesURLSafeBase64WithoutPadding(t *testing.T) {
+	// the standard encoding of 0xfb 0xff is "+/8=".
+	uri, err := ParseDataURI([]byte("data:application/octet-stream;base64,-_8"))
+	assert.Nil(t, err)
+
+	b, err := uri.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xfb, 0xff}, b)
+}
+
+func TestParseDataURIRejectsMalformedInput(t *testing.T) {
+	inputs := []string{
+		"",
+		"image/gif;base64,R0lG",
+		"data:image/gif;base64",
+		"data:image;base64,R0lG",
+		"data:image/gif;charset;base64,R0lG",
+		"data:image/gif;base64,R0l!",
+		"data:image/gif;base64,R0lGO",
+		"data:image/gif;base64,R0=lG",
+		"data:image/gif;base64,+-8=",
+		"data:text/plain,100%",
+	}
+
+	for _, input := range inputs {
+		uri, err := ParseDataURI([]byte(input))
+		assert.Equal(t, ErrInvalidDataURI, err, input)
+		assert.Nil(t, uri, input)
+	}
+}
+
+func TestNewBase64DecoderStreamsDataURI(t *testing.T) {
+	r := NewBase64Decoder(strings.NewReader("data:application/json;base64,eyJhIjox\nfQ=="))
+
+	b, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, string(b))
+}
+
+func TestNewBase64DecoderAcceptsURLSafeWithoutPadding(t *testing.T) {
+	b, err := ioutil.ReadAll(NewBase64Decoder(strings.NewReader("-_8")))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xfb, 0xff}, b)
+}
+
+func TestNewBase64DecoderLimitsHeaderLength(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("data:text/plain;"), neverEnding('a'))
+	_, err := ioutil.ReadAll(NewBase64Decoder(r))
+	assert.Equal(t, ErrInvalidDataURI, err)
+}
+
+// neverEnding is an endless stream of one byte.
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+
+	return len(p), nil
+}
+
+func TestNewBase64DecoderReportsMalformedData(t *testing.T) {
+	_, err := ioutil.ReadAll(NewBase64Decoder(strings.NewReader("eyJh!IjoxfQ==")))
+	assert.IsType(t, base64.CorruptInputError(0), err)
+
+	_, err = ioutil.ReadAll(NewBase64Decoder(strings.NewReader("data:text/plain,hello")))
+	assert.Equal(t, ErrInvalidDataURI, err)
+}
+
+func TestPutStreamSpoolsDecodedDataForFileSystemsWithoutStreaming(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewValidatingFileSystem(mem, ValidationPolicy{})
+
+	_, err := PutStream(fs, NewBase64Decoder(strings.NewReader("data:application/json;base64,eyJhIjoxfQ")), "a.json", PutOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, readAll(t, mem, "a.json"))
+}
+
+func TestPutStreamFailsOnMalformedData(t *testing.T) {
+	mem := NewMemoryFileSystem()
+
+	_, err := PutStream(mem, NewBase64Decoder(strings.NewReader("eyJh!IjoxfQ==")), "a.json", PutOptions{})
+	assert.NotNil(t, err)
+
+	_, err = mem.Get("a.json")
+	assert.True(t, IsNotExist(err))
+}
@@ -0,0 +1,540 @@
+package gofile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// encryptedChunkSize is the number of plaintext bytes sealed in each chunk of an encrypted file.
+const encryptedChunkSize = 64 * 1024
+
+// encryptedMagic marks the start of every file written by an EncryptedFileSystem.
+var encryptedMagic = []byte("GFE1")
+
+const (
+	encryptedPrefixSize = 7
+	encryptedKeySize    = 32
+)
+
+var (
+	// ErrDecrypt is returned when an encrypted file fails authentication, either because
+	// it has been tampered with or because it was encrypted with a different key.
+	ErrDecrypt = errors.New("gofile: unable to decrypt file")
+
+	// ErrNotEncrypted is returned when a file read through an EncryptedFileSystem
+	// was not written by one.
+	ErrNotEncrypted = errors.New("gofile: file is not encrypted")
+)
+
+// KeyProvider supplies the key encryption keys used by an EncryptedFileSystem. Each
+// file records the id of the key it was written with so that keys can be rotated
+// while files written under older keys stay readable.
+type KeyProvider interface {
+	// CurrentKey returns the id and the 256 bit key that new files are encrypted with.
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the given id.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider which holds its keys in memory.
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider is a construct function which takes the id of the key used for
+// new files and every key by id, including those only needed to read older files.
+func NewStaticKeyProvider(current string, keys map[string][]byte) *StaticKeyProvider {
+	return &StaticKeyProvider{
+		current,
+		keys,
+	}
+}
+
+// CurrentKey returns the key new files are encrypted with.
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.current)
+	return p.current, key, err
+}
+
+// Key returns the key with the given id.
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, errors.New("gofile: unknown encryption key " + id)
+	}
+
+	return key, nil
+}
+
+// EncryptedFileSystem wraps a FileSystem encrypting files before they are put and
+// decrypting them as they are read, so the wrapped storage only ever sees ciphertext.
+//
+// Every file is encrypted with its own random data key using AES-256-GCM, the data key
+// is in turn sealed with a key from the KeyProvider and stored in the file header.
+// Contents are sealed in fixed size chunks so that files are streamed rather than
+// buffered and can be seeked without decrypting everything before the offset.
+type EncryptedFileSystem struct {
+	fs   FileSystem
+	keys KeyProvider
+}
+
+// NewEncryptedFileSystem is a construct function which wraps fs, encrypting with keys from keys.
+func NewEncryptedFileSystem(fs FileSystem, keys KeyProvider) *EncryptedFileSystem {
+	return &EncryptedFileSystem{
+		fs,
+		keys,
+	}
+}
+
+// Put encrypts the contents of src and puts the ciphertext to the wrapped file system.
+func (e *EncryptedFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	r, err := e.encrypt(src)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := e.fs.Put(r, path)
+	if err != nil {
+		return file, err
+	}
+
+	return e.open(file, path)
+}
+
+// PutWithOptions encrypts the contents of src and puts the ciphertext to the wrapped
+// file system with the given options.
+func (e *EncryptedFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	r, err := e.encrypt(src)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := PutWithOptions(e.fs, r, path, opts)
+	if err != nil {
+		return file, err
+	}
+
+	return e.open(file, path)
+}
+
+// Get returns a File which decrypts the file at path as it is read.
+func (e *EncryptedFileSystem) Get(path string) (File, error) {
+	file, err := e.fs.Get(path)
+	if err != nil {
+		return file, err
+	}
+
+	return e.open(file, path)
+}
+
+// Delete removes the file at path from the wrapped file system.
+func (e *EncryptedFileSystem) Delete(path string) error {
+	return Delete(e.fs, path)
+}
+
+// List returns the paths in the wrapped file system starting with prefix.
+func (e *EncryptedFileSystem) List(prefix string) ([]string, error) {
+	return List(e.fs, prefix)
+}
+
+// encrypt generates a new data key and returns a reader of the encrypted contents of src.
+func (e *EncryptedFileSystem) encrypt(src io.ReadSeeker) (*encryptingReader, error) {
+	id, kek, err := e.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) > 255 {
+		return nil, errors.New("gofile: encryption key id is longer than 255 bytes")
+	}
+
+	dek := make([]byte, encryptedKeySize)
+	prefix := make([]byte, encryptedPrefixSize)
+	nonce := make([]byte, 12)
+	for _, b := range [][]byte{dek, prefix, nonce} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, err
+		}
+	}
+
+	wrap, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := append([]byte{}, encryptedMagic...)
+	ad = append(ad, byte(len(id)))
+	ad = append(ad, id...)
+
+	header := append([]byte{}, ad...)
+	header = append(header, nonce...)
+	header = wrap.Seal(header, nonce, dek, ad)
+	header = append(header, prefix...)
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	// the contents are encrypted from the current position of src, as every Put reads from.
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return &encryptingReader{
+		src:    src,
+		start:  start,
+		size:   end - start,
+		header: header,
+		aead:   aead,
+		prefix: prefix,
+		chunk:  -1,
+	}, nil
+}
+
+// open reads the header of an encrypted file and returns a File decrypting its contents.
+func (e *EncryptedFileSystem) open(file File, path string) (File, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, len(encryptedMagic)+1)
+	if _, err := io.ReadFull(file, head); err != nil || !bytes.Equal(head[:len(encryptedMagic)], encryptedMagic) {
+		return nil, ErrNotEncrypted
+	}
+
+	id := make([]byte, head[len(encryptedMagic)])
+	if _, err := io.ReadFull(file, id); err != nil {
+		return nil, ErrNotEncrypted
+	}
+
+	kek, err := e.keys.Key(string(id))
+	if err != nil {
+		return nil, err
+	}
+
+	wrap, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := make([]byte, wrap.NonceSize()+encryptedKeySize+wrap.Overhead()+encryptedPrefixSize)
+	if _, err := io.ReadFull(file, rest); err != nil {
+		return nil, ErrNotEncrypted
+	}
+
+	nonce := rest[:wrap.NonceSize()]
+	sealed := rest[wrap.NonceSize() : len(rest)-encryptedPrefixSize]
+	dek, err := wrap.Open(nil, nonce, sealed, append(head, id...))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := int64(len(head) + len(id) + len(rest))
+	end, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	size := plaintextSize(end-headerSize, aead.Overhead())
+	if size < 0 {
+		return nil, ErrDecrypt
+	}
+
+	return &EncryptedFile{
+		file:       file,
+		path:       path,
+		fs:         e,
+		aead:       aead,
+		prefix:     rest[len(rest)-encryptedPrefixSize:],
+		headerSize: headerSize,
+		size:       size,
+		chunk:      -1,
+	}, nil
+}
+
+// newGCM returns an AES-GCM cipher for a 256 bit key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptedKeySize {
+		return nil, errors.New("gofile: encryption keys must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// chunkCount returns the number of chunks a plaintext of size bytes is sealed in, an empty
+// file is still written as a single empty chunk so that truncation can be detected.
+func chunkCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+
+	return (size + encryptedChunkSize - 1) / encryptedChunkSize
+}
+
+// plaintextSize returns the size of the plaintext sealed in body bytes of chunks.
+func plaintextSize(body int64, overhead int) int64 {
+	sealed := int64(encryptedChunkSize + overhead)
+	chunks := (body + sealed - 1) / sealed
+	if rem := body % sealed; chunks == 0 || (rem != 0 && rem < int64(overhead)) {
+		return -1
+	}
+
+	return body - chunks*int64(overhead)
+}
+
+// chunkNonce returns the nonce for a chunk, the final chunk is flagged so that
+// chunks cannot be dropped from the end of a file.
+func chunkNonce(prefix []byte, chunk int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptedPrefixSize:], uint32(chunk))
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// encryptingReader is an io.ReadSeeker over the encrypted form of a plaintext
+// source, chunks are sealed as they are read so that only one is held in memory.
+type encryptingReader struct {
+	src    io.ReadSeeker
+	start  int64
+	size   int64
+	header []byte
+	aead   cipher.AEAD
+	prefix []byte
+	offset int64
+	chunk  int64
+	buf    []byte
+}
+
+// Read reads the next bytes of the header or sealed chunks.
+func (r *encryptingReader) Read(p []byte) (n int, err error) {
+	total := r.length()
+	for n < len(p) && r.offset < total {
+		if r.offset < int64(len(r.header)) {
+			c := copy(p[n:], r.header[r.offset:])
+			n += c
+			r.offset += int64(c)
+			continue
+		}
+
+		sealed := int64(encryptedChunkSize + r.aead.Overhead())
+		pos := r.offset - int64(len(r.header))
+		if err := r.seal(pos / sealed); err != nil {
+			return n, err
+		}
+
+		c := copy(p[n:], r.buf[pos%sealed:])
+		n += c
+		r.offset += int64(c)
+	}
+
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek sets the offset of the next Read in the encrypted stream.
+func (r *encryptingReader) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekOffset(r.offset, r.length(), offset, whence)
+	if err != nil {
+		return r.offset, err
+	}
+
+	r.offset = abs
+	return abs, nil
+}
+
+// length returns the total length of the encrypted stream.
+func (r *encryptingReader) length() int64 {
+	return int64(len(r.header)) + r.size + chunkCount(r.size)*int64(r.aead.Overhead())
+}
+
+// seal reads and encrypts a chunk of the source into the buffer.
+func (r *encryptingReader) seal(chunk int64) error {
+	if r.chunk == chunk {
+		return nil
+	}
+
+	start := chunk * encryptedChunkSize
+	plain := make([]byte, min64(encryptedChunkSize, r.size-start))
+	if _, err := r.src.Seek(r.start+start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r.src, plain); err != nil {
+		return err
+	}
+
+	last := chunk == chunkCount(r.size)-1
+	r.buf = r.aead.Seal(r.buf[:0], chunkNonce(r.prefix, chunk, last), plain, nil)
+	r.chunk = chunk
+
+	return nil
+}
+
+// EncryptedFile is a File which decrypts a file from the wrapped file system as it is read.
+type EncryptedFile struct {
+	file       File
+	path       string
+	fs         *EncryptedFileSystem
+	aead       cipher.AEAD
+	prefix     []byte
+	headerSize int64
+	size       int64
+	offset     int64
+	chunk      int64
+	buf        []byte
+}
+
+// Read decrypts the chunk holding the current offset and copies from it.
+func (f *EncryptedFile) Read(p []byte) (n int, err error) {
+	for n < len(p) && f.offset < f.size {
+		if err := f.open(f.offset / encryptedChunkSize); err != nil {
+			return n, err
+		}
+
+		c := copy(p[n:], f.buf[f.offset%encryptedChunkSize:])
+		n += c
+		f.offset += int64(c)
+	}
+
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek sets the plaintext offset of the next Read, only the chunk holding
+// the offset is decrypted when reading resumes.
+func (f *EncryptedFile) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekOffset(f.offset, f.size, offset, whence)
+	if err != nil {
+		return f.offset, err
+	}
+
+	f.offset = abs
+	return abs, nil
+}
+
+// Write replaces the contents of the file, encrypting p and putting it to the wrapped file system.
+func (f *EncryptedFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
+
+// Close closes the wrapped file.
+func (f *EncryptedFile) Close() error {
+	return f.file.Close()
+}
+
+// Stat returns the info of the wrapped file with the size of the decrypted contents,
+// the etag and checksum remain those of the stored ciphertext.
+func (f *EncryptedFile) Stat() (os.FileInfo, error) {
+	info, err := Stat(f.file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resizedFileInfo{info, f.size}, nil
+}
+
+// open reads and decrypts a chunk of the wrapped file into the buffer.
+func (f *EncryptedFile) open(chunk int64) error {
+	if f.chunk == chunk {
+		return nil
+	}
+
+	overhead := int64(f.aead.Overhead())
+	start := f.headerSize + chunk*(encryptedChunkSize+overhead)
+	sealed := make([]byte, min64(encryptedChunkSize, f.size-chunk*encryptedChunkSize)+overhead)
+
+	if _, err := f.file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(f.file, sealed); err != nil {
+		return err
+	}
+
+	last := chunk == chunkCount(f.size)-1
+	plain, err := f.aead.Open(f.buf[:0], chunkNonce(f.prefix, chunk, last), sealed, nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+
+	f.buf = plain
+	f.chunk = chunk
+
+	return nil
+}
+
+// seekOffset resolves a Seek call to an absolute offset in a stream of the given size.
+func seekOffset(current, size, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += current
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, errors.New("gofile: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("gofile: negative position")
+	}
+
+	return offset, nil
+}
+
+// min64 returns the smaller of two int64s.
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// resizedFileInfo overrides the size reported by a FileInfo, used by wrappers which
+// transform the stored contents of a file.
+type resizedFileInfo struct {
+	FileInfo
+	size int64
+}
+
+// Size returns the size of the transformed contents.
+func (i *resizedFileInfo) Size() int64 {
+	return i.size
+}
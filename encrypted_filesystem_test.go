@@ -0,0 +1,132 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encryptionKeys() *StaticKeyProvider {
+	return NewStaticKeyProvider("k1", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+}
+
+func TestEncryptedFileSystemRoundTripsContents(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewEncryptedFileSystem(mem, encryptionKeys())
+
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	_, err := fs.Put(bytes.NewReader(content), "secret.txt")
+	assert.Nil(t, err)
+
+	stored, _ := mem.Get("secret.txt")
+	raw, _ := ioutil.ReadAll(stored)
+	assert.False(t, bytes.Contains(raw, []byte("0123456789")))
+
+	file, err := fs.Get("secret.txt")
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadAll(file)
+	assert.Nil(t, err)
+	assert.Equal(t, content, b)
+
+	info, _ := file.Stat()
+	assert.Equal(t, int64(len(content)), info.Size())
+}
+
+func TestEncryptedFileSystemSeeksAcrossChunks(t *testing.T) {
+	fs := NewEncryptedFileSystem(NewMemoryFileSystem(), encryptionKeys())
+
+	content := make([]byte, 3*encryptedChunkSize+100)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	fs.Put(bytes.NewReader(content), "secret.bin")
+
+	file, _ := fs.Get("secret.bin")
+	offset := int64(2*encryptedChunkSize - 10)
+	pos, err := file.Seek(offset, io.SeekStart)
+	assert.Nil(t, err)
+	assert.Equal(t, offset, pos)
+
+	b := make([]byte, 20)
+	_, err = io.ReadFull(file, b)
+	assert.Nil(t, err)
+	assert.Equal(t, content[offset:offset+20], b)
+
+	file.Seek(-50, io.SeekEnd)
+	b, _ = ioutil.ReadAll(file)
+	assert.Equal(t, content[len(content)-50:], b)
+}
+
+func TestEncryptedFileSystemReadsFilesWrittenWithOlderKeys(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	keys := encryptionKeys()
+	NewEncryptedFileSystem(mem, keys).Put(bytes.NewReader([]byte("old")), "file.txt")
+
+	keys.current = "k2"
+	fs := NewEncryptedFileSystem(mem, keys)
+	fs.Put(bytes.NewReader([]byte("new")), "other.txt")
+
+	for path, expected := range map[string]string{"file.txt": "old", "other.txt": "new"} {
+		file, err := fs.Get(path)
+		assert.Nil(t, err)
+
+		b, _ := ioutil.ReadAll(file)
+		assert.Equal(t, expected, string(b))
+	}
+}
+
+func TestEncryptedFileSystemDetectsTampering(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewEncryptedFileSystem(mem, encryptionKeys())
+	fs.Put(bytes.NewReader([]byte("some secret contents")), "secret.txt")
+
+	stored, _ := mem.Get("secret.txt")
+	raw, _ := ioutil.ReadAll(stored)
+	raw[len(raw)-1] ^= 0xff
+	mem.Put(bytes.NewReader(raw), "secret.txt")
+
+	file, err := fs.Get("secret.txt")
+	assert.Nil(t, err)
+
+	_, err = ioutil.ReadAll(file)
+	assert.Equal(t, ErrDecrypt, err)
+}
+
+func TestEncryptedFileSystemRejectsPlainFiles(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("plain text")), "plain.txt")
+
+	_, err := NewEncryptedFileSystem(mem, encryptionKeys()).Get("plain.txt")
+	assert.Equal(t, ErrNotEncrypted, err)
+}
+
+func TestEncryptedFileSystemHandlesEmptyFiles(t *testing.T) {
+	fs := NewEncryptedFileSystem(NewMemoryFileSystem(), encryptionKeys())
+	fs.Put(bytes.NewReader(nil), "empty.txt")
+
+	file, err := fs.Get("empty.txt")
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadAll(file)
+	assert.Nil(t, err)
+	assert.Empty(t, b)
+}
+
+func TestEncryptedFileSystemEncryptsFromCurrentPosition(t *testing.T) {
+	fs := NewEncryptedFileSystem(NewMemoryFileSystem(), encryptionKeys())
+
+	src := bytes.NewReader([]byte("header:contents"))
+	src.Seek(7, io.SeekStart)
+
+	_, err := fs.Put(src, "secret.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, fs, "secret.txt"))
+}
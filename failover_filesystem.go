@@ -0,0 +1,419 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker of a FailoverFileSystem.
+type CircuitState int
+
+const (
+	// CircuitClosed sends every operation to the primary.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen sends every operation to the secondary until the cooldown has passed.
+	CircuitOpen
+
+	// CircuitHalfOpen sends a single probe operation to the primary to test whether it has recovered.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	defaultFailoverThreshold = 5
+	defaultFailoverCooldown  = 30 * time.Second
+)
+
+// FailoverOptions configures the circuit breaker of a FailoverFileSystem.
+type FailoverOptions struct {
+	// Threshold is the number of consecutive primary errors which open the circuit, defaults to 5.
+	Threshold int
+
+	// Cooldown is how long the circuit stays open before the primary is probed, defaults to 30 seconds.
+	Cooldown time.Duration
+}
+
+// StateChangeFunc is called by a FailoverFileSystem when its circuit changes state.
+type StateChangeFunc func(from, to CircuitState)
+
+// FailoverFileSystem routes operations to a primary file system and falls back to a
+// secondary, for example a local spool, when the primary fails. Consecutive primary
+// errors open a circuit which sends every operation straight to the secondary until a
+// probe after the cooldown succeeds.
+//
+// A file which does not exist is not counted as an error. Puts and deletes which go to the
+// secondary are recorded and replayed to the primary by Reconcile. Until then Get serves
+// files put during the outage from the secondary and reports files deleted during the
+// outage as not existing, even once the primary has recovered. The record is kept in
+// memory, so changes made before a restart stay on the secondary and are not replayed.
+// List only lists the file system the operation is routed to.
+type FailoverFileSystem struct {
+	primary   FileSystem
+	secondary FileSystem
+	opts      FailoverOptions
+	time      Time
+	onChange  StateChangeFunc
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	pending  map[string]*failoverChange
+}
+
+// failoverChange is a put or delete made to the secondary which has not reached the primary.
+type failoverChange struct {
+	deleted bool
+	opts    PutOptions
+}
+
+// NewFailoverFileSystem is a construct function which routes to primary, failing over to secondary.
+func NewFailoverFileSystem(primary, secondary FileSystem, opts FailoverOptions) *FailoverFileSystem {
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultFailoverThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultFailoverCooldown
+	}
+
+	return &FailoverFileSystem{
+		primary:   primary,
+		secondary: secondary,
+		opts:      opts,
+		time:      new(OSTime),
+		pending:   make(map[string]*failoverChange),
+	}
+}
+
+// SetStateChangeFunc sets the hook called whenever the circuit changes state.
+func (f *FailoverFileSystem) SetStateChangeFunc(fn StateChangeFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.onChange = fn
+}
+
+// State returns the current state of the circuit.
+func (f *FailoverFileSystem) State() CircuitState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.state
+}
+
+// Put writes the file to the primary, or the secondary if the primary is failing.
+func (f *FailoverFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return f.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file with the given options to the primary, or the secondary
+// if the primary is failing.
+func (f *FailoverFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	start, _ := src.Seek(0, io.SeekCurrent)
+
+	var file File
+	secondary, err := f.do(func(fs FileSystem) (err error) {
+		if _, err := src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+
+		file, err = PutWithOptions(fs, src, path, opts)
+		if err != nil && file != nil {
+			file.Close()
+			file = nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if secondary {
+		f.record(path, &failoverChange{opts: opts})
+	} else {
+		f.record(path, nil)
+	}
+
+	return f.newFile(file, path), nil
+}
+
+// Get returns the file from the primary, or the secondary if the primary is failing or
+// does not hold the file. Files changed during an outage which have not been reconciled
+// are read from the secondary.
+func (f *FailoverFileSystem) Get(path string) (File, error) {
+	if change := f.change(path); change != nil {
+		if change.deleted {
+			return nil, notExist("get", path)
+		}
+
+		file, err := f.secondary.Get(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return f.newFile(file, path), nil
+	}
+
+	var file File
+	_, err := f.do(func(fs FileSystem) (err error) {
+		file, err = fs.Get(path)
+		return err
+	})
+
+	if IsNotExist(err) {
+		file, err = f.secondary.Get(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f.newFile(file, path), nil
+}
+
+// Delete removes the file from the primary, or the secondary if the primary is failing.
+// A delete which goes to the secondary succeeds even if only the primary holds the file,
+// it is recorded and replayed to the primary by Reconcile.
+func (f *FailoverFileSystem) Delete(path string) error {
+	secondary, err := f.do(func(fs FileSystem) error {
+		return Delete(fs, path)
+	})
+
+	if secondary {
+		if err != nil && !IsNotExist(err) {
+			return err
+		}
+
+		f.record(path, &failoverChange{deleted: true})
+		return nil
+	}
+
+	if err == nil && f.change(path) != nil {
+		// the secondary may hold a copy put during the outage which Get would fall back to.
+		Delete(f.secondary, path)
+		f.record(path, nil)
+	}
+
+	return err
+}
+
+// Reconcile replays the puts and deletes made to the secondary while the primary was failing
+// to the primary, deleting the copies of the files put from the secondary. It stops at the
+// first error, the changes not yet replayed are kept for the next call. Reconcile can be
+// called once the circuit closes, for example:
+//
+//	fs.SetStateChangeFunc(func(from, to gofile.CircuitState) {
+//		if to == gofile.CircuitClosed {
+//			go fs.Reconcile()
+//		}
+//	})
+func (f *FailoverFileSystem) Reconcile() error {
+	f.mu.Lock()
+	paths := make([]string, 0, len(f.pending))
+	changes := make(map[string]*failoverChange, len(f.pending))
+	for path, change := range f.pending {
+		paths = append(paths, path)
+		changes[path] = change
+	}
+	f.mu.Unlock()
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		change := changes[path]
+		if err := f.replay(path, change); err != nil {
+			return err
+		}
+
+		f.mu.Lock()
+		// a change made while replaying is left for the next call.
+		if f.pending[path] == change {
+			delete(f.pending, path)
+		}
+		f.mu.Unlock()
+	}
+
+	return nil
+}
+
+// replay applies a change made to the secondary to the primary.
+func (f *FailoverFileSystem) replay(path string, change *failoverChange) error {
+	if change.deleted {
+		if err := Delete(f.primary, path); err != nil && !IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	file, err := f.secondary.Get(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := PutWithOptions(f.primary, file, path, change.opts); err != nil {
+		return err
+	}
+
+	if err := Delete(f.secondary, path); err != nil && !IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// record sets the change waiting to be replayed for path, a nil change clears it.
+func (f *FailoverFileSystem) record(path string, change *failoverChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if change == nil {
+		delete(f.pending, path)
+		return
+	}
+
+	f.pending[path] = change
+}
+
+// change returns the change waiting to be replayed for path, or nil.
+func (f *FailoverFileSystem) change(path string) *failoverChange {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.pending[path]
+}
+
+// List lists the primary, or the secondary if the primary is failing.
+func (f *FailoverFileSystem) List(prefix string) ([]string, error) {
+	var paths []string
+	_, err := f.do(func(fs FileSystem) (err error) {
+		paths, err = List(fs, prefix)
+		return err
+	})
+
+	return paths, err
+}
+
+// do runs op against the primary when the circuit allows it, running it against the
+// secondary if the circuit is open or the primary fails. secondary reports whether op
+// was run against the secondary.
+func (f *FailoverFileSystem) do(op func(fs FileSystem) error) (secondary bool, err error) {
+	if f.allow() {
+		err := op(f.primary)
+		if err == nil || IsNotExist(err) || err == ErrNotSupported {
+			f.succeed()
+			return false, err
+		}
+
+		f.fail()
+	}
+
+	return true, op(f.secondary)
+}
+
+// allow reports whether the next operation should go to the primary, moving an open
+// circuit to half open once the cooldown has passed so that the operation probes the primary.
+func (f *FailoverFileSystem) allow() bool {
+	f.mu.Lock()
+
+	switch f.state {
+	case CircuitClosed:
+		f.mu.Unlock()
+		return true
+	case CircuitOpen:
+		if f.time.Now().Sub(f.openedAt) < f.opts.Cooldown {
+			f.mu.Unlock()
+			return false
+		}
+
+		f.transition(CircuitHalfOpen)
+		return true
+	default:
+		// a probe is already in flight.
+		f.mu.Unlock()
+		return false
+	}
+}
+
+// succeed records a successful primary operation, closing the circuit after a probe.
+func (f *FailoverFileSystem) succeed() {
+	f.mu.Lock()
+
+	f.failures = 0
+	if f.state != CircuitHalfOpen {
+		f.mu.Unlock()
+		return
+	}
+
+	f.transition(CircuitClosed)
+}
+
+// fail records a failed primary operation, opening the circuit once the threshold is
+// reached or a probe fails.
+func (f *FailoverFileSystem) fail() {
+	f.mu.Lock()
+
+	f.failures++
+	if f.state == CircuitOpen || (f.state == CircuitClosed && f.failures < f.opts.Threshold) {
+		f.mu.Unlock()
+		return
+	}
+
+	f.openedAt = f.time.Now()
+	f.transition(CircuitOpen)
+}
+
+// transition moves the circuit to a new state, unlocks and emits the state change.
+// The caller must hold the lock.
+func (f *FailoverFileSystem) transition(to CircuitState) {
+	from := f.state
+	f.state = to
+	onChange := f.onChange
+	f.mu.Unlock()
+
+	if onChange != nil {
+		onChange(from, to)
+	}
+}
+
+// newFile wraps a file so that writes to it go through the failover file system.
+func (f *FailoverFileSystem) newFile(file File, path string) *FailoverFile {
+	return &FailoverFile{
+		file,
+		path,
+		f,
+	}
+}
+
+// FailoverFile is a File read from a FailoverFileSystem.
+type FailoverFile struct {
+	File
+	path string
+	fs   *FailoverFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *FailoverFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the failover file system.
+func (f *FailoverFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
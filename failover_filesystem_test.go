@@ -0,0 +1,194 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setUpFailoverFileSystem() (*FailoverFileSystem, *MockFileSystem, *MemoryFileSystem, *time.Time, *[]string) {
+	primary := new(MockFileSystem)
+	secondary := NewMemoryFileSystem()
+
+	now := time.Unix(1000, 0)
+	timer := new(MockTime)
+	timer.On("Now").Return(func() time.Time { return now })
+
+	fs := NewFailoverFileSystem(primary, secondary, FailoverOptions{Threshold: 2, Cooldown: time.Minute})
+	fs.time = timer
+
+	var events []string
+	fs.SetStateChangeFunc(func(from, to CircuitState) {
+		events = append(events, from.String()+"->"+to.String())
+	})
+
+	return fs, primary, secondary, &now, &events
+}
+
+func TestFailoverFileSystemUsesPrimaryWhenHealthy(t *testing.T) {
+	fs, primary, secondary, _, _ := setUpFailoverFileSystem()
+	primary.On("Put", mock.Anything, "file.txt").Return(new(MemoryFile), nil)
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+
+	_, err = secondary.Get("file.txt")
+	assert.True(t, IsNotExist(err))
+	primary.AssertExpectations(t)
+}
+
+func TestFailoverFileSystemRetriesSecondaryFromStartOffset(t *testing.T) {
+	fs, primary, secondary, _, _ := setUpFailoverFileSystem()
+
+	partial := new(MockFile)
+	partial.On("Close").Return(nil)
+	primary.On("Put", mock.Anything, "file.txt").Run(func(args mock.Arguments) {
+		ioutil.ReadAll(args.Get(0).(io.Reader))
+	}).Return(partial, errors.New("down"))
+
+	src := bytes.NewReader([]byte("header:contents"))
+	src.Seek(7, io.SeekStart)
+
+	_, err := fs.Put(src, "file.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, secondary, "file.txt"))
+	partial.AssertNumberOfCalls(t, "Close", 1)
+}
+
+func TestFailoverFileSystemOpensCircuitAfterConsecutiveErrors(t *testing.T) {
+	fs, primary, secondary, _, events := setUpFailoverFileSystem()
+	primary.On("Put", mock.Anything, "file.txt").Return(nil, errors.New("down")).Twice()
+
+	_, err := fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, CircuitClosed, fs.State())
+
+	_, err = fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, CircuitOpen, fs.State())
+	assert.Equal(t, []string{"closed->open"}, *events)
+
+	_, err = fs.Put(bytes.NewReader([]byte("three")), "file.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "three", readAll(t, secondary, "file.txt"))
+	primary.AssertNumberOfCalls(t, "Put", 2)
+}
+
+func TestFailoverFileSystemHalfOpenProbe(t *testing.T) {
+	fs, primary, _, now, events := setUpFailoverFileSystem()
+	primary.On("Put", mock.Anything, "file.txt").Return(nil, errors.New("down")).Times(3)
+	primary.On("Put", mock.Anything, "file.txt").Return(new(MemoryFile), nil)
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+
+	*now = now.Add(2 * time.Minute)
+	fs.Put(bytes.NewReader([]byte("probe")), "file.txt")
+	assert.Equal(t, CircuitOpen, fs.State())
+
+	fs.Put(bytes.NewReader([]byte("skipped")), "file.txt")
+	primary.AssertNumberOfCalls(t, "Put", 3)
+
+	*now = now.Add(2 * time.Minute)
+	_, err := fs.Put(bytes.NewReader([]byte("probe")), "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, CircuitClosed, fs.State())
+
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, *events)
+}
+
+func TestFailoverFileSystemGetFindsSpooledFiles(t *testing.T) {
+	fs, primary, secondary, _, _ := setUpFailoverFileSystem()
+	primary.On("Get", "file.txt").Return(nil, notExist("get", "file.txt"))
+	secondary.Put(bytes.NewReader([]byte("spooled")), "file.txt")
+
+	assert.Equal(t, "spooled", readAll(t, fs, "file.txt"))
+	assert.Equal(t, CircuitClosed, fs.State())
+}
+
+func TestFailoverFileSystemReconcileReplaysOutageChanges(t *testing.T) {
+	backend := NewMemoryFileSystem()
+	backend.Put(bytes.NewReader([]byte("old")), "a.txt")
+	backend.Put(bytes.NewReader([]byte("kept")), "b.txt")
+	primary := &flakyFileSystem{FileSystem: backend}
+	secondary := NewMemoryFileSystem()
+
+	fs := NewFailoverFileSystem(primary, secondary, FailoverOptions{})
+
+	primary.down = true
+	_, err := fs.Put(bytes.NewReader([]byte("new")), "a.txt")
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Delete("b.txt"))
+	primary.down = false
+
+	assert.Equal(t, "new", readAll(t, fs, "a.txt"))
+	_, err = fs.Get("b.txt")
+	assert.True(t, IsNotExist(err))
+	assert.Equal(t, "old", readAll(t, backend, "a.txt"))
+
+	assert.Nil(t, fs.Reconcile())
+
+	assert.Equal(t, "new", readAll(t, backend, "a.txt"))
+	_, err = backend.Get("b.txt")
+	assert.True(t, IsNotExist(err))
+	_, err = secondary.Get("a.txt")
+	assert.True(t, IsNotExist(err))
+	assert.Equal(t, "new", readAll(t, fs, "a.txt"))
+}
+
+func TestFailoverFileSystemReconcileKeepsChangesWhilePrimaryIsDown(t *testing.T) {
+	primary := &flakyFileSystem{FileSystem: NewMemoryFileSystem(), down: true}
+	secondary := NewMemoryFileSystem()
+	fs := NewFailoverFileSystem(primary, secondary, FailoverOptions{})
+
+	fs.Put(bytes.NewReader([]byte("spooled")), "a.txt")
+	assert.NotNil(t, fs.Reconcile())
+
+	primary.down = false
+	assert.Nil(t, fs.Reconcile())
+	assert.Equal(t, "spooled", readAll(t, primary, "a.txt"))
+}
+
+// flakyFileSystem fails every operation while down.
+type flakyFileSystem struct {
+	FileSystem
+	down bool
+}
+
+func (f *flakyFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	if f.down {
+		return nil, errors.New("down")
+	}
+
+	return f.FileSystem.Put(src, path)
+}
+
+func (f *flakyFileSystem) Get(path string) (File, error) {
+	if f.down {
+		return nil, errors.New("down")
+	}
+
+	return f.FileSystem.Get(path)
+}
+
+func (f *flakyFileSystem) Delete(path string) error {
+	if f.down {
+		return errors.New("down")
+	}
+
+	return Delete(f.FileSystem, path)
+}
@@ -0,0 +1,162 @@
+package gofile
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ServeOptions configures how files are served over http.
+type ServeOptions struct {
+	// PresignExpiry redirects requests for the files of a file system which implements
+	// Presigner to a presigned url valid for this long, so the contents are downloaded from
+	// the backend directly rather than through the server. Zero serves every file directly.
+	PresignExpiry time.Duration
+}
+
+// fileServer serves the files of a FileSystem, see FileServer.
+type fileServer struct {
+	fs   FileSystem
+	opts ServeOptions
+}
+
+// FileServer returns a http.Handler serving the file at the path of each request from fs,
+// see ServeFile. Mount it under a prefix with http.StripPrefix.
+func FileServer(fs FileSystem) http.Handler {
+	return NewFileServer(fs, ServeOptions{})
+}
+
+// NewFileServer is a construct function for a http.Handler serving the files of fs with the given options.
+func NewFileServer(fs FileSystem, opts ServeOptions) http.Handler {
+	return &fileServer{
+		fs,
+		opts,
+	}
+}
+
+// ServeHTTP serves the file at the path of the request.
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveFile(w, r, s.fs, strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/"), s.opts)
+}
+
+// ServeFile replies to a GET or HEAD request with the contents of the file at path in fs.
+// The Content-Type, Content-Length, Last-Modified and ETag headers are set from the info of
+// the file, Range requests are answered with partial content and If-None-Match and
+// If-Modified-Since are answered with 304 Not Modified without reading the file.
+func ServeFile(w http.ResponseWriter, r *http.Request, fs FileSystem, path string) {
+	serveFile(w, r, fs, path, ServeOptions{})
+}
+
+// serveFile serves a file with the given options.
+func serveFile(w http.ResponseWriter, r *http.Request, fs FileSystem, path string, opts ServeOptions) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := StatPath(fs, path)
+	if err != nil {
+		serveError(w, r, err)
+		return
+	}
+
+	// directories are not served, file systems such as OSFileSystem can stat them.
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if p, ok := fs.(Presigner); ok && opts.PresignExpiry > 0 {
+		url, err := p.Presign(path, opts.PresignExpiry)
+		if err != nil {
+			serveError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+		return
+	}
+
+	if info.ETag() != "" {
+		w.Header().Set("ETag", info.ETag())
+	}
+
+	if notModified(r, info) {
+		if !info.ModTime().IsZero() {
+			w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if info.ContentType() != "" {
+		w.Header().Set("Content-Type", info.ContentType())
+	}
+
+	file, err := fs.Get(path)
+	if err != nil {
+		serveError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// notModified reports whether the conditional headers of the request show that the client
+// already holds the file. As in RFC 7232 If-Modified-Since is ignored when If-None-Match is sent.
+func notModified(r *http.Request, info FileInfo) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		etag := strings.TrimPrefix(info.ETag(), "W/")
+		if etag == "" {
+			return false
+		}
+
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || info.ModTime().IsZero() {
+		return false
+	}
+
+	return !info.ModTime().Truncate(time.Second).After(since)
+}
+
+// serveError replies with the status matching an error from a file system.
+func serveError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case IsNotExist(err):
+		http.NotFound(w, r)
+	case os.IsPermission(err):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	case isPathError(err, ErrPathEscape), isPathError(err, ErrInvalidPath):
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// isPathError reports whether err is target, or an *os.PathError wrapping it.
+func isPathError(err, target error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+
+	return err == target
+}
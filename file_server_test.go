@@ -0,0 +1,127 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(handler http.Handler, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestFileServerServesContentsWithHeaders(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	file, _ := mem.Put(bytes.NewReader([]byte("hello world")), "docs/hello.txt")
+	info, _ := Stat(file)
+
+	w := serve(FileServer(mem), http.MethodGet, "/docs/hello.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello world", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+	assert.Equal(t, info.ETag(), w.Header().Get("ETag"))
+	assert.Equal(t, info.ModTime().UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	w = serve(FileServer(mem), http.MethodHead, "/docs/hello.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Body.String())
+}
+
+func TestFileServerServesRanges(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("hello world")), "hello.txt")
+
+	w := serve(FileServer(mem), http.MethodGet, "/hello.txt", map[string]string{"Range": "bytes=6-"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "world", w.Body.String())
+	assert.Equal(t, "bytes 6-10/11", w.Header().Get("Content-Range"))
+}
+
+func TestFileServerAnswersConditionalRequests(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	file, _ := mem.Put(bytes.NewReader([]byte("hello world")), "hello.txt")
+	info, _ := Stat(file)
+
+	w := serve(FileServer(mem), http.MethodGet, "/hello.txt", map[string]string{"If-None-Match": `"other", ` + info.ETag()})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, "", w.Body.String())
+
+	w = serve(FileServer(mem), http.MethodGet, "/hello.txt", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	since := info.ModTime().Add(time.Minute).UTC().Format(http.TimeFormat)
+	w = serve(FileServer(mem), http.MethodGet, "/hello.txt", map[string]string{"If-Modified-Since": since})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	since = info.ModTime().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	w = serve(FileServer(mem), http.MethodGet, "/hello.txt", map[string]string{"If-Modified-Since": since})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestFileServerErrors(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("hello")), "hello.txt")
+
+	assert.Equal(t, http.StatusNotFound, serve(FileServer(mem), http.MethodGet, "/missing.txt", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(FileServer(mem), http.MethodGet, "/", nil).Code)
+
+	w := serve(FileServer(mem), http.MethodPost, "/hello.txt", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+}
+
+func TestFileServerDoesNotServeDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofile-serve")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	osfs := Sub(NewOSFileSystem(), dir)
+	osfs.Put(bytes.NewReader([]byte("hello")), "docs/hello.txt")
+
+	w := serve(FileServer(osfs), http.MethodGet, "/docs", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(FileServer(osfs), http.MethodGet, "/docs/hello.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+}
+
+func TestFileServerRedirectsToPresignedURL(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String("videos/big.mp4"),
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("HeadObject", &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String("videos/big.mp4"),
+	}).Return(&s3.HeadObjectOutput{ContentLength: aws.Int64(10)}, nil)
+	caller.On("PresignGetObject", params, 5*time.Minute).Return("https://signed.example/videos/big.mp4?sig=abc", nil)
+
+	w := serve(NewFileServer(fs, ServeOptions{PresignExpiry: 5 * time.Minute}), http.MethodGet, "/videos/big.mp4", nil)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://signed.example/videos/big.mp4?sig=abc", w.Header().Get("Location"))
+	caller.AssertNotCalled(t, "GetObject", params)
+}
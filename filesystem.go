@@ -3,16 +3,27 @@ package gofile
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"io"
 	"io/ioutil"
 	"mime"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
+)
+
+var (
+	base64ImagePrefix = regexp.MustCompile("data:(image/[^;]+);base64,")
+	base64ImageType   = regexp.MustCompile("data:image/([^;]+);base64,")
+	whitespace        = regexp.MustCompile("\\s+")
 )
 
 // Base64ToDecoder take an input of base64 bytes and strips the encoding signature.
 // returns a bytes reader so as to conform with the ReadSeeker interface
+//
+// Deprecated: use ParseDataURI, which supports every media type and reports malformed input.
 func Base64ToDecoder(src []byte) io.ReadSeeker {
 	reader := bytes.NewReader(StripBaseEncoding(src))
 	b, _ := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, reader))
@@ -21,15 +32,17 @@ func Base64ToDecoder(src []byte) io.ReadSeeker {
 }
 
 // StripBaseEncoding strips the base encoding prefece from an encoded image.
+//
+// Deprecated: use ParseDataURI.
 func StripBaseEncoding(image []byte) []byte {
-	r := regexp.MustCompile("data:(image/[^;]+);base64,")
-	return r.ReplaceAll(image, []byte(""))
+	return base64ImagePrefix.ReplaceAll(image, []byte(""))
 }
 
 // Base64ImageType returns the extension of a base64 encoded image.
+//
+// Deprecated: use ParseDataURI and DataURI.Extension.
 func Base64ImageType(image []byte) string {
-	r := regexp.MustCompile("data:image/([^;]+);base64,")
-	matches := r.FindSubmatch(image)
+	matches := base64ImageType.FindSubmatch(image)
 
 	if len(matches) < 2 {
 		return "txt"
@@ -39,9 +52,10 @@ func Base64ImageType(image []byte) string {
 }
 
 // SanitizePath removes whitespace from the path so that files can be persisted without error.
+// The file systems clean paths with a PathPolicy, which also handles unicode, control
+// characters and "..".
 func SanitizePath(path string) string {
-	reg := regexp.MustCompile("\\s+")
-	return reg.ReplaceAllString(strings.TrimSpace(path), "-")
+	return whitespace.ReplaceAllString(strings.TrimSpace(path), "-")
 }
 
 // GetMIMETypeFromPath returns mime type from a path to a file.
@@ -84,3 +98,385 @@ type File interface {
 	io.ReadWriteSeeker
 	Stat() (os.FileInfo, error)
 }
+
+// ErrNotSupported is returned when an operation is called on a file system which does not implement it.
+var ErrNotSupported = errors.New("gofile: operation not supported by file system")
+
+// Deleter is implemented by file systems which can remove files.
+type Deleter interface {
+	Delete(path string) error
+}
+
+// Delete removes the file at path from fs, returning ErrNotSupported if fs cannot delete files.
+func Delete(fs FileSystem, path string) error {
+	if d, ok := fs.(Deleter); ok {
+		return d.Delete(path)
+	}
+
+	return ErrNotSupported
+}
+
+// Lister is implemented by file systems which can list the files they hold.
+type Lister interface {
+	// List returns the sorted paths of every file whose path starts with prefix.
+	List(prefix string) ([]string, error)
+}
+
+// List returns the paths in fs starting with prefix, returning ErrNotSupported if fs cannot list files.
+func List(fs FileSystem, prefix string) ([]string, error) {
+	if l, ok := fs.(Lister); ok {
+		return l.List(prefix)
+	}
+
+	return nil, ErrNotSupported
+}
+
+// Mover is implemented by file systems which can move a file without copying it through the caller.
+type Mover interface {
+	Move(from, to string) error
+}
+
+// Move moves the file at from to to in fs. File systems which do not implement Mover have
+// the file copied with Get and Put and the original removed with Delete.
+func Move(fs FileSystem, from, to string) error {
+	if m, ok := fs.(Mover); ok {
+		return m.Move(from, to)
+	}
+
+	file, err := fs.Get(from)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := fs.Put(file, to); err != nil {
+		return err
+	}
+
+	return Delete(fs, from)
+}
+
+// Stater is implemented by file systems which can return the info of a file without
+// reading its contents.
+type Stater interface {
+	Stat(path string) (FileInfo, error)
+}
+
+// StatPath returns the FileInfo of the file at path in fs, falling back to getting the
+// file if fs does not implement Stater.
+func StatPath(fs FileSystem, path string) (FileInfo, error) {
+	if s, ok := fs.(Stater); ok {
+		return s.Stat(path)
+	}
+
+	file, err := fs.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Stat(file)
+}
+
+// IsNotExist reports whether an error returned from a file system means the file does not exist,
+// covering both os errors and the not found codes returned by s3.
+func IsNotExist(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+
+	if e, ok := err.(interface {
+		Code() string
+	}); ok {
+		switch e.Code() {
+		case "NoSuchKey", "NoSuchVersion", "NotFound":
+			return true
+		}
+	}
+
+	return false
+}
+
+// PutOptions configures how a single file is stored. Backends ignore the options they do not support.
+type PutOptions struct {
+	// ContentType is the mime type the file is stored with, by default it is detected from
+	// the path and contents.
+	ContentType string
+
+	// ContentEncoding is the encoding applied to the contents, e.g. gzip.
+	ContentEncoding string
+
+	// Metadata holds user defined metadata stored with the file.
+	Metadata map[string]string
+
+	// ACL is the canned access control list applied to the object, e.g. private or public-read.
+	ACL string
+
+	// StorageClass is the storage class of the object, e.g. STANDARD_IA or GLACIER_IR.
+	StorageClass string
+
+	// ServerSideEncryption is the server side encryption algorithm, AES256 or aws:kms.
+	ServerSideEncryption string
+
+	// SSEKMSKeyId is the id of the kms key used for aws:kms encryption.
+	SSEKMSKeyId string
+
+	// SSECustomerKey is a raw 256 bit customer provided encryption key, the same key
+	// must be provided to read the object back.
+	SSECustomerKey string
+
+	// Tagging holds the tags set on the object.
+	Tagging map[string]string
+}
+
+// merge returns the options with every field set in over taking precedence, tags are combined.
+func (o PutOptions) merge(over PutOptions) PutOptions {
+	if over.ContentType != "" {
+		o.ContentType = over.ContentType
+	}
+	if over.ContentEncoding != "" {
+		o.ContentEncoding = over.ContentEncoding
+	}
+	if over.ACL != "" {
+		o.ACL = over.ACL
+	}
+	if over.StorageClass != "" {
+		o.StorageClass = over.StorageClass
+	}
+	if over.ServerSideEncryption != "" {
+		o.ServerSideEncryption = over.ServerSideEncryption
+	}
+	if over.SSEKMSKeyId != "" {
+		o.SSEKMSKeyId = over.SSEKMSKeyId
+	}
+	if over.SSECustomerKey != "" {
+		o.SSECustomerKey = over.SSECustomerKey
+	}
+
+	o.Metadata = mergeMaps(o.Metadata, over.Metadata)
+	o.Tagging = mergeMaps(o.Tagging, over.Tagging)
+
+	return o
+}
+
+// putOptionsFromInfo returns the options which store a copy of a file like the one
+// described by info, for backends whose FileInfo reports them.
+func putOptionsFromInfo(info FileInfo) PutOptions {
+	opts := PutOptions{
+		ContentType: info.ContentType(),
+		Metadata:    info.Metadata(),
+	}
+
+	if i, ok := info.(interface {
+		ContentEncoding() string
+	}); ok {
+		opts.ContentEncoding = i.ContentEncoding()
+	}
+	if i, ok := info.(interface {
+		StorageClass() string
+	}); ok {
+		opts.StorageClass = i.StorageClass()
+	}
+
+	return opts
+}
+
+// mergeMaps returns a copy of a with the entries of b added, a is returned as is if b is empty.
+func mergeMaps(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// OptionsPutter is implemented by file systems which accept options for each Put.
+type OptionsPutter interface {
+	PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error)
+}
+
+// PutWithOptions puts a file with the given options when the file system supports them
+// and falls back to a plain Put when it does not.
+func PutWithOptions(fs FileSystem, src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	if p, ok := fs.(OptionsPutter); ok {
+		return p.PutWithOptions(src, path, opts)
+	}
+
+	return fs.Put(src, path)
+}
+
+// StreamPutter is implemented by file systems which can write a file from a reader which
+// cannot seek, such as a request body or a decoder, without holding it all in memory.
+type StreamPutter interface {
+	PutStream(src io.Reader, path string, opts PutOptions) (File, error)
+}
+
+// PutStream writes the contents of src to path in fs with the given options, errors reading
+// src, such as malformed base64, fail the put. File systems which do not implement
+// StreamPutter have src spooled to a temporary file on disk which is then put with PutWithOptions.
+func PutStream(fs FileSystem, src io.Reader, path string, opts PutOptions) (File, error) {
+	if p, ok := fs.(StreamPutter); ok {
+		return p.PutStream(src, path, opts)
+	}
+
+	if rs, ok := src.(io.ReadSeeker); ok {
+		return PutWithOptions(fs, rs, path, opts)
+	}
+
+	tmp, err := ioutil.TempFile("", "gofile-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return PutWithOptions(fs, tmp, path, opts)
+}
+
+// URLer is implemented by files which can be reached at a public url, such as objects on s3.
+type URLer interface {
+	URL() string
+}
+
+// fileWrapper is implemented by the files of the file systems in this package which wrap
+// another, so that helpers can look through them. It is unexported so that files which must
+// not be reached directly, such as those of ReadOnly, are not exposed to callers.
+type fileWrapper interface {
+	unwrap() File
+}
+
+// URL returns the public url of f, looking through the files of wrapping file systems such
+// as Sub, ReadOnly and CachedFileSystem. ok is false if neither f nor a file it wraps
+// implements URLer. The files of an EncryptedFileSystem are not looked through as their
+// url would serve the ciphertext.
+func URL(f File) (url string, ok bool) {
+	for f != nil {
+		if u, ok := f.(URLer); ok {
+			return u.URL(), true
+		}
+
+		w, ok := f.(fileWrapper)
+		if !ok {
+			break
+		}
+		f = w.unwrap()
+	}
+
+	return "", false
+}
+
+// Presigner is implemented by file systems which can create a temporary url that downloads
+// a file directly from the backend, such as a presigned s3 url.
+type Presigner interface {
+	Presign(path string, expires time.Duration) (string, error)
+}
+
+// FileInfo extends os.FileInfo with the object metadata that storage backends hold
+// about a file. Every File returned from a gofile FileSystem returns a FileInfo from Stat,
+// fields a backend does not support are returned as their zero value.
+type FileInfo interface {
+	os.FileInfo
+
+	// ETag returns the entity tag of the file, formatted as a quoted string as it
+	// would appear in a http header.
+	ETag() string
+
+	// ContentType returns the mime type of the file.
+	ContentType() string
+
+	// Checksum returns the hex encoded md5 digest of the file contents.
+	Checksum() string
+
+	// Version returns the version id of the file if the backend supports versioning.
+	Version() string
+
+	// Metadata returns user defined metadata stored with the file.
+	Metadata() map[string]string
+}
+
+// Stat returns the FileInfo of a File. Files that return a plain os.FileInfo,
+// for example an *os.File, have it wrapped so that the extended fields are always available.
+func Stat(f File) (FileInfo, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi, ok := info.(FileInfo); ok {
+		return fi, nil
+	}
+
+	return plainFileInfo{info}, nil
+}
+
+// plainFileInfo adapts an os.FileInfo to the FileInfo interface with empty metadata.
+type plainFileInfo struct {
+	os.FileInfo
+}
+
+// ETag returns an empty string as the underlying info holds no entity tag.
+func (plainFileInfo) ETag() string { return "" }
+
+// ContentType guesses the mime type from the name of the file.
+func (p plainFileInfo) ContentType() string { return GetMIMETypeFromPath(p.Name()) }
+
+// Checksum returns an empty string as the underlying info holds no checksum.
+func (plainFileInfo) Checksum() string { return "" }
+
+// Version returns an empty string as the underlying info holds no version.
+func (plainFileInfo) Version() string { return "" }
+
+// Metadata returns nil as the underlying info holds no metadata.
+func (plainFileInfo) Metadata() map[string]string { return nil }
+
+// Versioner is implemented by file systems which keep a history of every file that is put.
+type Versioner interface {
+	// Versions lists the versions of the file at path, newest first.
+	Versions(path string) ([]Version, error)
+
+	// GetVersion returns the contents of the file at path as they were at the given version.
+	GetVersion(path, id string) (File, error)
+
+	// DeleteVersion permanently removes a single version of the file at path.
+	DeleteVersion(path, id string) error
+
+	// RestoreVersion makes the given version the current version of the file at path.
+	RestoreVersion(path, id string) (File, error)
+}
+
+// Version describes a single version of a file held by a Versioner.
+type Version struct {
+	Key          string
+	ID           string
+	IsLatest     bool
+	DeleteMarker bool
+	Size         int64
+	ETag         string
+	ModTime      time.Time
+}
+
+// sortVersions orders versions newest first.
+func sortVersions(versions []Version) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+
+		return versions[i].ModTime.After(versions[j].ModTime)
+	})
+}
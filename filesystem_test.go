@@ -1,8 +1,12 @@
 package gofile
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,3 +61,71 @@ func TestGetMIMETypeFromPath(t *testing.T) {
 		assert.Equal(t, expected, actual, "input: "+input)
 	}
 }
+
+func TestPutWithOptionsFallsBackToPut(t *testing.T) {
+	fs := NewMockFilesystem()
+	file := new(MockFile)
+	src := bytes.NewReader([]byte("contents"))
+
+	fs.On("Put", src, "file.txt").Return(file, nil)
+
+	actual, err := PutWithOptions(fs, src, "file.txt", PutOptions{ACL: "private"})
+	assert.Nil(t, err)
+	assert.Equal(t, file, actual)
+}
+
+func TestDeleteReturnsErrNotSupported(t *testing.T) {
+	assert.Equal(t, ErrNotSupported, Delete(NewMockFilesystem(), "file.txt"))
+}
+
+type codedError struct {
+	error
+	code string
+}
+
+func (e codedError) Code() string { return e.code }
+
+func TestIsNotExist(t *testing.T) {
+	assert.True(t, IsNotExist(os.ErrNotExist))
+	assert.True(t, IsNotExist(notExist("get", "file.txt")))
+	assert.True(t, IsNotExist(codedError{errors.New("missing"), "NoSuchKey"}))
+	assert.False(t, IsNotExist(codedError{errors.New("denied"), "AccessDenied"}))
+	assert.False(t, IsNotExist(errors.New("other")))
+}
+
+func TestMoveCopiesAndDeletesWithoutMover(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.Put(bytes.NewReader([]byte("contents")), "from.txt")
+
+	assert.Nil(t, Move(fs, "from.txt", "to.txt"))
+
+	_, err := fs.Get("from.txt")
+	assert.True(t, IsNotExist(err))
+
+	file, err := fs.Get("to.txt")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "contents", string(b))
+}
+
+func TestURLLooksThroughWrappedFiles(t *testing.T) {
+	s3fs, _, _ := setUpS3FileSystem("bucket", getConfig("region"))
+	now := time.Now()
+	s3file := NewS3File([]byte("contents"), "images/a.png", &now, s3fs)
+
+	backend := new(MockFileSystem)
+	backend.On("Get", "images/a.png").Return(s3file, nil)
+
+	fs := Sub(ReadOnly(NewMirrorFileSystem(QuorumAny, backend)), "images")
+	file, err := fs.Get("a.png")
+	assert.Nil(t, err)
+
+	url, ok := URL(file)
+	assert.True(t, ok)
+	assert.Equal(t, "https://s3-region.amazonaws.com/bucket/images/a.png", url)
+
+	mem := NewMemoryFileSystem()
+	file, _ = mem.Put(bytes.NewReader([]byte("contents")), "a.txt")
+	_, ok = URL(file)
+	assert.False(t, ok)
+}
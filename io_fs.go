@@ -0,0 +1,391 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errIsDir is returned when a directory is read as a file.
+var errIsDir = errors.New("is a directory")
+
+// ioFS adapts a FileSystem to io/fs, see AsFS.
+type ioFS struct {
+	fs FileSystem
+}
+
+// AsFS returns an io/fs.FS reading from fs, so a gofile file system can be passed to
+// template.ParseFS, http.FS, fs.WalkDir and the like. The returned FS implements fs.StatFS,
+// fs.ReadDirFS, fs.ReadFileFS and fs.SubFS. Directories are made up from the paths of
+// the files, so listing them and opening them needs fs to implement Lister.
+func AsFS(fsys FileSystem) fs.FS {
+	return &ioFS{fsys}
+}
+
+// Open opens the file or directory at name.
+func (f *ioFS) Open(name string) (fs.File, error) {
+	if err := checkPath("open", name); err != nil {
+		return nil, err
+	}
+
+	if name != "." {
+		file, err := f.fs.Get(name)
+		if err == nil {
+			info, err := file.Stat()
+			if err == nil && !info.IsDir() {
+				return file, nil
+			}
+			file.Close()
+		} else if !IsNotExist(err) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	entries, err := f.readDir("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ioDir{name: name, entries: entries}, nil
+}
+
+// Stat returns the info of the file or directory at name. Directories are made up from
+// the listed paths even if the file system stores them, so Stat agrees with Open.
+func (f *ioFS) Stat(name string) (fs.FileInfo, error) {
+	if err := checkPath("stat", name); err != nil {
+		return nil, err
+	}
+
+	if name != "." {
+		info, err := StatPath(f.fs, name)
+		if err == nil && !info.IsDir() {
+			return info, nil
+		}
+		if err != nil && !IsNotExist(err) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+
+	if _, err := f.readDir("stat", name); err != nil {
+		return nil, err
+	}
+
+	return dirInfo(path.Base(name)), nil
+}
+
+// ReadFile reads the whole file at name.
+func (f *ioFS) ReadFile(name string) ([]byte, error) {
+	if err := checkPath("readfile", name); err != nil {
+		return nil, err
+	}
+
+	file, err := f.fs.Get(name)
+	if err != nil {
+		if IsNotExist(err) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+// ReadDir lists the directory at name sorted by file name.
+func (f *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := checkPath("readdir", name); err != nil {
+		return nil, err
+	}
+
+	return f.readDir("readdir", name)
+}
+
+// Sub returns an FS holding the files under dir.
+func (f *ioFS) Sub(dir string) (fs.FS, error) {
+	if err := checkPath("sub", dir); err != nil {
+		return nil, err
+	}
+
+	if dir == "." {
+		return f, nil
+	}
+
+	return &ioFS{Sub(f.fs, dir)}, nil
+}
+
+// checkPath checks that name is a valid io/fs path. Backslashes are not separators in
+// io/fs but are cleaned into them by file systems, so names holding one do not exist.
+func checkPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if strings.Contains(name, "\\") {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// readDir lists the files and directories directly under name from the paths the file
+// system lists, a directory with nothing in it does not exist.
+func (f *ioFS) readDir(op, name string) ([]fs.DirEntry, error) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+
+	paths, err := List(f.fs, prefix)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	var entries []fs.DirEntry
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, prefix), "/")
+		if rel == "" {
+			continue
+		}
+
+		if i := strings.IndexByte(rel, '/'); i >= 0 {
+			dir := rel[:i]
+			if !dirs[dir] {
+				dirs[dir] = true
+				entries = append(entries, fs.FileInfoToDirEntry(dirInfo(dir)))
+			}
+			continue
+		}
+
+		entries = append(entries, &ioDirEntry{f.fs, p, rel})
+	}
+
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// ioDirEntry is a file listed in a directory, its info is only fetched when asked for.
+type ioDirEntry struct {
+	fs   FileSystem
+	path string
+	name string
+}
+
+// Name returns the name of the file.
+func (e *ioDirEntry) Name() string { return e.name }
+
+// IsDir returns false as listed paths are files.
+func (e *ioDirEntry) IsDir() bool { return false }
+
+// Type returns the type bits of a regular file.
+func (e *ioDirEntry) Type() fs.FileMode { return 0 }
+
+// Info returns the info of the file from the file system.
+func (e *ioDirEntry) Info() (fs.FileInfo, error) {
+	return StatPath(e.fs, e.path)
+}
+
+// dirInfo is the info of a directory made up from the paths of the files in it.
+type dirInfo string
+
+// Name returns the name of the directory.
+func (d dirInfo) Name() string { return string(d) }
+
+// Size returns 0.
+func (d dirInfo) Size() int64 { return 0 }
+
+// Mode returns a read only directory mode.
+func (d dirInfo) Mode() fs.FileMode { return fs.ModeDir | 0555 }
+
+// ModTime returns the zero time as directories are not stored.
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+
+// IsDir returns true.
+func (d dirInfo) IsDir() bool { return true }
+
+// Sys returns nil.
+func (d dirInfo) Sys() interface{} { return nil }
+
+// ioDir is an open directory of an FS returned by AsFS.
+type ioDir struct {
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+// Stat returns the info of the directory.
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return dirInfo(path.Base(d.name)), nil
+}
+
+// Read fails as a directory has no contents.
+func (d *ioDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+// Close does nothing.
+func (d *ioDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or every remaining entry if n <= 0.
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+
+	return remaining[:n], nil
+}
+
+// IOFileSystem is a read only FileSystem reading from an io/fs.FS, see FromFS.
+type IOFileSystem struct {
+	fsys fs.FS
+}
+
+// FromFS returns a read only FileSystem reading from fsys, so that an embed.FS, an
+// fstest.MapFS or a directory from os.DirFS can be used as a backend. Paths are cleaned
+// and any leading "/" is dropped. Put and Delete fail with ErrReadOnly.
+func FromFS(fsys fs.FS) FileSystem {
+	return &IOFileSystem{fsys}
+}
+
+// Put returns ErrReadOnly.
+func (i *IOFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return nil, ErrReadOnly
+}
+
+// PutWithOptions returns ErrReadOnly.
+func (i *IOFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	return nil, ErrReadOnly
+}
+
+// Delete returns ErrReadOnly.
+func (i *IOFileSystem) Delete(path string) error {
+	return ErrReadOnly
+}
+
+// Get opens the file at path, files which cannot seek are read into memory.
+func (i *IOFileSystem) Get(p string) (File, error) {
+	name := fsPath(p)
+
+	file, err := i.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, notExist("get", p)
+	}
+
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return &IOFile{rs, file, info}, nil
+	}
+
+	content, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IOFile{bytes.NewReader(content), ioutil.NopCloser(nil), info}, nil
+}
+
+// Stat returns the info of the file at path.
+func (i *IOFileSystem) Stat(p string) (FileInfo, error) {
+	info, err := fs.Stat(i.fsys, fsPath(p))
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, notExist("stat", p)
+	}
+
+	return plainFileInfo{info}, nil
+}
+
+// List walks the directory of prefix and returns the paths of every file starting with prefix.
+func (i *IOFileSystem) List(prefix string) ([]string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	root := path.Dir(prefix)
+
+	var paths []string
+	err := fs.WalkDir(i.fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if IsNotExist(err) && p == root {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		if !d.IsDir() && strings.HasPrefix(p, prefix) {
+			paths = append(paths, p)
+		}
+
+		return nil
+	})
+
+	sort.Strings(paths)
+	return paths, err
+}
+
+// fsPath converts a gofile path to a valid io/fs path.
+func fsPath(p string) string {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+// IOFile is a File from an IOFileSystem, writes to it fail with ErrReadOnly.
+type IOFile struct {
+	io.ReadSeeker
+	closer io.Closer
+	info   fs.FileInfo
+}
+
+// Write returns ErrReadOnly without writing anything.
+func (f *IOFile) Write(p []byte) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Close closes the underlying file.
+func (f *IOFile) Close() error {
+	return f.closer.Close()
+}
+
+// Stat returns the info of the file.
+func (f *IOFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
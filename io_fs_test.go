@@ -0,0 +1,131 @@
+package gofile
+
+import (
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMemoryFS(t *testing.T, files map[string]string) *MemoryFileSystem {
+	mem := NewMemoryFileSystem()
+	for p, content := range files {
+		_, err := mem.Put(bytes.NewReader([]byte(content)), p)
+		assert.Nil(t, err)
+	}
+
+	return mem
+}
+
+func TestAsFSPassesFSTest(t *testing.T) {
+	mem := newTestMemoryFS(t, map[string]string{
+		"index.html":            "<h1>hi</h1>",
+		"static/app.js":         "console.log(1)",
+		"static/css/site.css":   "body {}",
+		"templates/layout.tmpl": "{{.}}",
+	})
+
+	err := fstest.TestFS(AsFS(mem), "index.html", "static/app.js", "static/css/site.css", "templates/layout.tmpl")
+	assert.Nil(t, err)
+}
+
+func TestAsFSPassesFSTestOnOSFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofile-iofs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	osfs := Sub(NewOSFileSystem(), dir)
+	for _, p := range []string{"index.html", "static/app.js", "static/css/site.css"} {
+		_, err := osfs.Put(bytes.NewReader([]byte(p)), p)
+		assert.Nil(t, err)
+	}
+
+	err = fstest.TestFS(AsFS(osfs), "index.html", "static/app.js", "static/css/site.css")
+	assert.Nil(t, err)
+}
+
+func TestAsFSReadsDirectoriesAndFiles(t *testing.T) {
+	fsys := AsFS(newTestMemoryFS(t, map[string]string{
+		"a.txt":         "a",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+	}))
+
+	entries, err := fs.ReadDir(fsys, "dir")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "b.txt", entries[0].Name())
+	assert.False(t, entries[0].IsDir())
+	assert.Equal(t, "sub", entries[1].Name())
+	assert.True(t, entries[1].IsDir())
+
+	b, err := fs.ReadFile(fsys, "dir/sub/c.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", string(b))
+
+	info, err := fs.Stat(fsys, "dir/sub")
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = fsys.Open("missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fsys.Open("../a.txt")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	sub, err := fs.Sub(fsys, "dir")
+	assert.Nil(t, err)
+	b, err = fs.ReadFile(sub, "sub/c.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", string(b))
+}
+
+func TestFromFSReadsMapFS(t *testing.T) {
+	now := time.Now()
+	fsys := FromFS(fstest.MapFS{
+		"a.txt":     {Data: []byte("a"), ModTime: now},
+		"dir/b.txt": {Data: []byte("bee")},
+	})
+
+	file, err := fsys.Get("/dir/b.txt")
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "bee", string(b))
+
+	info, err := StatPath(fsys, "a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", info.Name())
+	assert.Equal(t, now, info.ModTime())
+
+	paths, err := List(fsys, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "dir/b.txt"}, paths)
+
+	paths, err = List(fsys, "dir/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir/b.txt"}, paths)
+
+	_, err = fsys.Get("dir")
+	assert.True(t, IsNotExist(err))
+
+	_, err = fsys.Get("missing.txt")
+	assert.True(t, IsNotExist(err))
+}
+
+func TestFromFSIsReadOnly(t *testing.T) {
+	fsys := FromFS(fstest.MapFS{"a.txt": {Data: []byte("a")}})
+
+	_, err := fsys.Put(bytes.NewReader([]byte("b")), "a.txt")
+	assert.Equal(t, ErrReadOnly, err)
+	assert.Equal(t, ErrReadOnly, Delete(fsys, "a.txt"))
+
+	file, _ := fsys.Get("a.txt")
+	_, err = file.Write([]byte("b"))
+	assert.Equal(t, ErrReadOnly, err)
+}
@@ -0,0 +1,320 @@
+package gofile
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// KeyGenerator generates the key a file is stored at, so that handlers do not each invent a scheme.
+type KeyGenerator interface {
+	// Key returns the key for a file with the contents of src and the extension ext,
+	// such as ".png" or an empty string. src is left where it started.
+	Key(src io.ReadSeeker, ext string) (string, error)
+}
+
+// KeyGeneratorFunc adapts a function to the KeyGenerator interface.
+type KeyGeneratorFunc func(src io.ReadSeeker, ext string) (string, error)
+
+// Key calls the function.
+func (f KeyGeneratorFunc) Key(src io.ReadSeeker, ext string) (string, error) {
+	return f(src, ext)
+}
+
+// UUIDKeyGenerator generates keys from random version 4 uuids, e.g. 0f8fad5b-d9cb-469f-a165-70867728950e.png.
+type UUIDKeyGenerator struct {
+	rand io.Reader
+}
+
+// NewUUIDKeyGenerator is a construct function which generates uuid keys from crypto/rand.
+func NewUUIDKeyGenerator() *UUIDKeyGenerator {
+	return &UUIDKeyGenerator{
+		rand.Reader,
+	}
+}
+
+// Key returns a new random uuid with the extension.
+func (g *UUIDKeyGenerator) Key(src io.ReadSeeker, ext string) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(g.rand, b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x%s", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16], ext), nil
+}
+
+// crockford is the base32 alphabet ulids are encoded with.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDKeyGenerator generates keys from ulids, e.g. 01ARZ3NDEKTSV4RRFFQ69G5FAV.png, which sort
+// in the order they were generated to the millisecond.
+type ULIDKeyGenerator struct {
+	time Time
+	rand io.Reader
+}
+
+// NewULIDKeyGenerator is a construct function which generates ulid keys from the current time and crypto/rand.
+func NewULIDKeyGenerator() *ULIDKeyGenerator {
+	return &ULIDKeyGenerator{
+		new(OSTime),
+		rand.Reader,
+	}
+}
+
+// Key returns a new ulid with the extension.
+func (g *ULIDKeyGenerator) Key(src io.ReadSeeker, ext string) (string, error) {
+	var entropy [10]byte
+	if _, err := io.ReadFull(g.rand, entropy[:]); err != nil {
+		return "", err
+	}
+
+	var id [26]byte
+
+	// the first 10 characters hold the 48 bit millisecond timestamp.
+	ms := uint64(g.time.Now().UnixNano() / 1e6)
+	for i := 9; i >= 0; i-- {
+		id[i] = crockford[ms&31]
+		ms >>= 5
+	}
+
+	// the last 16 characters hold the 80 random bits, 5 at a time.
+	var bits uint
+	var buf uint64
+	i := 10
+	for _, b := range entropy {
+		buf = buf<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			id[i] = crockford[(buf>>bits)&31]
+			i++
+		}
+	}
+
+	return string(id[:]) + ext, nil
+}
+
+// HashKeyGenerator generates keys from the hex encoded sha256 digest of the contents, so
+// that identical files share a key.
+type HashKeyGenerator struct{}
+
+// NewHashKeyGenerator is a construct function which generates content hash keys.
+func NewHashKeyGenerator() *HashKeyGenerator {
+	return &HashKeyGenerator{}
+}
+
+// Key hashes the contents of src and rewinds it.
+func (g *HashKeyGenerator) Key(src io.ReadSeeker, ext string) (string, error) {
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return "", err
+	}
+
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) + ext, nil
+}
+
+// DateKeyGenerator partitions the keys of another generator by the date they were
+// generated on, e.g. 2024/03/09/0f8fad5b-d9cb-469f-a165-70867728950e.png.
+type DateKeyGenerator struct {
+	keys KeyGenerator
+	time Time
+}
+
+// NewDateKeyGenerator is a construct function which prefixes the keys of keys with the
+// current utc date as yyyy/mm/dd/. A nil keys generates uuids.
+func NewDateKeyGenerator(keys KeyGenerator) *DateKeyGenerator {
+	if keys == nil {
+		keys = NewUUIDKeyGenerator()
+	}
+
+	return &DateKeyGenerator{
+		keys,
+		new(OSTime),
+	}
+}
+
+// Key returns the key of the wrapped generator under the current date.
+func (g *DateKeyGenerator) Key(src io.ReadSeeker, ext string) (string, error) {
+	key, err := g.keys.Key(src, ext)
+	if err != nil {
+		return "", err
+	}
+
+	return g.time.Now().UTC().Format("2006/01/02/") + key, nil
+}
+
+// ShardedKeyGenerator prefixes the keys of another generator with characters of their hash,
+// e.g. 3f/a1/2024/03/09/photo.png, so that keys which would otherwise share a prefix, such
+// as sequential or date partitioned keys, are spread across s3 partitions.
+type ShardedKeyGenerator struct {
+	keys   KeyGenerator
+	levels int
+	width  int
+}
+
+// NewShardedKeyGenerator is a construct function which prefixes the keys of keys with levels
+// directories of width hex characters each. Levels and width default to 1 and 2, a nil keys
+// generates uuids.
+func NewShardedKeyGenerator(keys KeyGenerator, levels, width int) *ShardedKeyGenerator {
+	if keys == nil {
+		keys = NewUUIDKeyGenerator()
+	}
+	if levels <= 0 {
+		levels = 1
+	}
+	if width <= 0 {
+		width = 2
+	}
+
+	return &ShardedKeyGenerator{
+		keys,
+		levels,
+		width,
+	}
+}
+
+// Key returns the key of the wrapped generator under its shard.
+func (g *ShardedKeyGenerator) Key(src io.ReadSeeker, ext string) (string, error) {
+	key, err := g.keys.Key(src, ext)
+	if err != nil {
+		return "", err
+	}
+
+	// an md5 digest gives 32 hex characters to shard with.
+	sum := md5.Sum([]byte(key))
+	digest := strings.Repeat(hex.EncodeToString(sum[:]), 1+g.levels*g.width/32)
+
+	var prefix bytes.Buffer
+	for i := 0; i < g.levels; i++ {
+		prefix.WriteString(digest[i*g.width : (i+1)*g.width])
+		prefix.WriteByte('/')
+	}
+
+	return prefix.String() + key, nil
+}
+
+// AutoKeyFileSystem puts files to a wrapped file system at keys from a KeyGenerator, see PutAuto.
+type AutoKeyFileSystem struct {
+	fs     FileSystem
+	keys   KeyGenerator
+	prefix string
+}
+
+// NewAutoKeyFileSystem is a construct function which puts files to fs at keys from keys,
+// a nil keys generates uuids.
+func NewAutoKeyFileSystem(fs FileSystem, keys KeyGenerator) *AutoKeyFileSystem {
+	if keys == nil {
+		keys = NewUUIDKeyGenerator()
+	}
+
+	return &AutoKeyFileSystem{
+		fs:   fs,
+		keys: keys,
+	}
+}
+
+// SetPrefix sets the directory generated keys are put under, so that with "avatars" a file
+// is put at a key such as "avatars/0f8fad5b-d9cb-469f-a165-70867728950e.png".
+func (a *AutoKeyFileSystem) SetPrefix(prefix string) {
+	a.prefix = strings.Trim(prefix, "/")
+}
+
+// PutAuto puts the file at a generated key under the prefix and returns the key. The
+// extension is picked from the type detected from the contents, falling back to the
+// extension of hint when the contents are plain text or binary. hint is the original name
+// of the file as given by a client, or an empty string. Only the extension of its base name
+// is used, so a hint can never choose where the file is put.
+func (a *AutoKeyFileSystem) PutAuto(src io.ReadSeeker, hint string) (File, string, error) {
+	return a.PutAutoWithOptions(src, hint, PutOptions{})
+}
+
+// PutAutoWithOptions puts the file at a generated key like PutAuto with the given options.
+func (a *AutoKeyFileSystem) PutAutoWithOptions(src io.ReadSeeker, hint string, opts PutOptions) (File, string, error) {
+	detected, err := DetectContentType(src)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ext := hintExtension(hint)
+	if t := mediaType(detected); ext == "" || (t != "text/plain" && t != "application/octet-stream") {
+		if detectedExt := extensionForType(t); detectedExt != "" {
+			ext = detectedExt
+		}
+	}
+
+	key, err := a.keys.Key(src, ext)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if a.prefix != "" {
+		key = a.prefix + "/" + key
+	}
+
+	file, err := PutWithOptions(a.fs, src, key, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return file, key, nil
+}
+
+// hintExtension returns the lower cased extension of the base name of hint, or an empty
+// string if it has none or it holds anything other than letters and digits.
+func hintExtension(hint string) string {
+	ext := strings.ToLower(path.Ext(path.Base(strings.Replace(hint, "\\", "/", -1))))
+	if len(ext) < 2 {
+		return ""
+	}
+
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+
+	return ext
+}
+
+// Put writes the file to the wrapped file system at path.
+func (a *AutoKeyFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return a.fs.Put(src, path)
+}
+
+// PutWithOptions writes the file to the wrapped file system at path with the given options.
+func (a *AutoKeyFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	return PutWithOptions(a.fs, src, path, opts)
+}
+
+// Get returns the file from the wrapped file system.
+func (a *AutoKeyFileSystem) Get(path string) (File, error) {
+	return a.fs.Get(path)
+}
+
+// Delete removes the file from the wrapped file system.
+func (a *AutoKeyFileSystem) Delete(path string) error {
+	return Delete(a.fs, path)
+}
+
+// List lists the files of the wrapped file system starting with prefix.
+func (a *AutoKeyFileSystem) List(prefix string) ([]string, error) {
+	return List(a.fs, prefix)
+}
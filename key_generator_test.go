@@ -0,0 +1,114 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUIDKeyGeneratorGeneratesVersion4UUIDs(t *testing.T) {
+	g := &UUIDKeyGenerator{bytes.NewReader(bytes.Repeat([]byte{0xff}, 16))}
+
+	key, err := g.Key(bytes.NewReader(nil), ".png")
+	assert.Nil(t, err)
+	assert.Equal(t, "ffffffff-ffff-4fff-bfff-ffffffffffff.png", key)
+
+	key, err = NewUUIDKeyGenerator().Key(bytes.NewReader(nil), "")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"), key)
+}
+
+func TestULIDKeyGeneratorEncodesTimeAndEntropy(t *testing.T) {
+	timer := new(MockTime)
+	timer.On("Now").Return(time.Unix(0, 1469918176385*int64(time.Millisecond)))
+
+	g := &ULIDKeyGenerator{timer, bytes.NewReader(make([]byte, 10))}
+
+	key, err := g.Key(bytes.NewReader(nil), ".txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "01ARYZ6S410000000000000000.txt", key)
+}
+
+func TestHashKeyGeneratorHashesAndRewindsContents(t *testing.T) {
+	src := bytes.NewReader([]byte("hello"))
+
+	key, err := NewHashKeyGenerator().Key(src, ".txt")
+	assert.Nil(t, err)
+	assert.Equal(t, helloHash+".txt", key)
+
+	pos, _ := src.Seek(0, io.SeekCurrent)
+	assert.Equal(t, int64(0), pos)
+}
+
+func TestDateKeyGeneratorPartitionsByUTCDate(t *testing.T) {
+	timer := new(MockTime)
+	timer.On("Now").Return(time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("behind", -2*60*60)))
+
+	g := &DateKeyGenerator{NewHashKeyGenerator(), timer}
+
+	key, err := g.Key(bytes.NewReader([]byte("hello")), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "2024/03/10/"+helloHash, key)
+}
+
+func TestShardedKeyGeneratorPrefixesWithHashOfKey(t *testing.T) {
+	fixed := KeyGeneratorFunc(func(src io.ReadSeeker, ext string) (string, error) {
+		return "photo" + ext, nil
+	})
+
+	key, err := NewShardedKeyGenerator(fixed, 2, 2).Key(bytes.NewReader(nil), ".png")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{2}/[0-9a-f]{2}/photo.png$"), key)
+
+	again, _ := NewShardedKeyGenerator(fixed, 2, 2).Key(bytes.NewReader(nil), ".png")
+	assert.Equal(t, key, again)
+
+	key, err = NewShardedKeyGenerator(fixed, 3, 20).Key(bytes.NewReader(nil), ".png")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{20}/[0-9a-f]{20}/[0-9a-f]{20}/photo.png$"), key)
+}
+
+func TestPutAutoPicksExtensionFromContents(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewAutoKeyFileSystem(mem, NewHashKeyGenerator())
+
+	// the contents are a png whatever the hint says.
+	_, key, err := fs.PutAuto(bytes.NewReader(pngHeader), "me.jpg")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{64}\\.png$"), key)
+	assert.Equal(t, string(pngHeader), readAll(t, mem, key))
+
+	// plain text keeps the extension of the hint.
+	_, key, err = fs.PutAuto(bytes.NewReader([]byte("a,b\n1,2\n")), "report.csv")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{64}\\.csv$"), key)
+
+	_, key, err = fs.PutAuto(bytes.NewReader([]byte("hello")), "")
+	assert.Nil(t, err)
+	assert.Equal(t, helloHash+".txt", key)
+}
+
+func TestPutAutoIgnoresDirectoryOfHint(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewAutoKeyFileSystem(mem, NewHashKeyGenerator())
+
+	for _, hint := range []string{"/tmp/escape/evil.sh", "../../evil.sh", "..\\..\\evil.sh", "avatars/evil.sh"} {
+		_, key, err := fs.PutAuto(bytes.NewReader([]byte("#!/bin/sh\n")), hint)
+		assert.Nil(t, err)
+		assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{64}\\.sh$"), key)
+	}
+
+	_, key, err := fs.PutAuto(bytes.NewReader([]byte("hello")), "notes.t/xt")
+	assert.Nil(t, err)
+	assert.Equal(t, helloHash+".txt", key)
+
+	fs.SetPrefix("/avatars/")
+	_, key, err = fs.PutAuto(bytes.NewReader(pngHeader), "/tmp/escape/me.jpg")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^avatars/[0-9a-f]{64}\\.png$"), key)
+	assert.Equal(t, string(pngHeader), readAll(t, mem, key))
+}
@@ -0,0 +1,470 @@
+package gofile
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryFileSystem implements the FileSystem interface by holding files in memory.
+// every Put is kept as a new version of the file so that it can stand in for a
+// versioned s3 bucket in tests, unless versioning is turned off with SetVersioning.
+type MemoryFileSystem struct {
+	mu          sync.RWMutex
+	files       map[string][]*memoryObject
+	time        Time
+	next        int
+	unversioned bool
+	policy      PathPolicy
+}
+
+// memoryObject is a single version of a file held by the MemoryFileSystem.
+type memoryObject struct {
+	content      []byte
+	mod          time.Time
+	version      string
+	contentType  string
+	encoding     string
+	metadata     map[string]string
+	deleteMarker bool
+}
+
+// NewMemoryFileSystem is a construct function that returns a pointer to an empty MemoryFileSystem.
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{
+		files:  make(map[string][]*memoryObject),
+		time:   new(OSTime),
+		policy: PathPolicy{Sanitize: true},
+	}
+}
+
+// SetVersioning turns keeping the history of files on or off, it is on by default. With
+// versioning off a Put replaces the file and a Delete removes it, so that memory is only
+// held for the current contents, for example when the file system is used as a cache.
+func (fs *MemoryFileSystem) SetVersioning(enabled bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.unversioned = !enabled
+}
+
+// SetPathPolicy sets the policy paths are cleaned with, it should be set before files are put.
+func (fs *MemoryFileSystem) SetPathPolicy(policy PathPolicy) {
+	fs.policy = policy
+}
+
+// CleanPath returns the key path is stored at under the path policy.
+func (fs *MemoryFileSystem) CleanPath(path string) (string, error) {
+	return fs.policy.Clean(path)
+}
+
+// Put stores the contents of the reader as the newest version of the file at path.
+func (fs *MemoryFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return fs.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions stores the contents of the reader like Put, keeping the metadata of the options.
+func (fs *MemoryFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	return fs.PutStream(src, path, opts)
+}
+
+// PutStream stores the contents of a reader which cannot seek like PutWithOptions.
+func (fs *MemoryFileSystem) PutStream(src io.Reader, path string, opts PutOptions) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(MemoryFile), err
+	}
+
+	content, err := ioutil.ReadAll(src)
+	if err != nil {
+		return new(MemoryFile), err
+	}
+
+	obj := &memoryObject{
+		content:     content,
+		contentType: opts.ContentType,
+		encoding:    opts.ContentEncoding,
+		metadata:    opts.Metadata,
+	}
+	if obj.contentType == "" {
+		obj.contentType = GetMIMETypeFromPath(path)
+	}
+
+	fs.mu.Lock()
+	fs.push(path, obj)
+	fs.mu.Unlock()
+
+	return fs.newFile(path, obj), nil
+}
+
+// Get returns the latest version of the file at path.
+func (fs *MemoryFileSystem) Get(path string) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(MemoryFile), err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	versions := fs.files[path]
+	if len(versions) == 0 || versions[len(versions)-1].deleteMarker {
+		return new(MemoryFile), notExist("get", path)
+	}
+
+	return fs.newFile(path, versions[len(versions)-1]), nil
+}
+
+// Stat returns the info of the latest version of the file at path.
+func (fs *MemoryFileSystem) Stat(path string) (FileInfo, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	versions := fs.files[path]
+	if len(versions) == 0 || versions[len(versions)-1].deleteMarker {
+		return nil, notExist("stat", path)
+	}
+
+	return fs.newFile(path, versions[len(versions)-1]).info, nil
+}
+
+// Delete adds a delete marker as the newest version of the file at path, the history
+// of the file is kept so that older versions can still be restored. With versioning off
+// the file is removed.
+func (fs *MemoryFileSystem) Delete(path string) error {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	versions := fs.files[path]
+	if len(versions) == 0 || versions[len(versions)-1].deleteMarker {
+		return notExist("delete", path)
+	}
+
+	if fs.unversioned {
+		delete(fs.files, path)
+		return nil
+	}
+
+	fs.push(path, &memoryObject{deleteMarker: true})
+	return nil
+}
+
+// List returns the paths of every file starting with prefix which has not been deleted.
+func (fs *MemoryFileSystem) List(prefix string) ([]string, error) {
+	prefix, err := fs.policy.cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	var paths []string
+	for path, versions := range fs.files {
+		if strings.HasPrefix(path, prefix) && !versions[len(versions)-1].deleteMarker {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Versions lists every version of the file at path, newest first.
+func (fs *MemoryFileSystem) Versions(path string) ([]Version, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	objs := fs.files[path]
+	if len(objs) == 0 {
+		return nil, notExist("versions", path)
+	}
+
+	versions := make([]Version, 0, len(objs))
+	for i := len(objs) - 1; i >= 0; i-- {
+		obj := objs[i]
+		versions = append(versions, Version{
+			Key:          path,
+			ID:           obj.version,
+			IsLatest:     i == len(objs)-1,
+			DeleteMarker: obj.deleteMarker,
+			Size:         int64(len(obj.content)),
+			ETag:         obj.etag(),
+			ModTime:      obj.mod,
+		})
+	}
+
+	return versions, nil
+}
+
+// GetVersion returns the contents of a specific version of the file at path.
+func (fs *MemoryFileSystem) GetVersion(path, id string) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(MemoryFile), err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	i := fs.find(path, id)
+	if i < 0 || fs.files[path][i].deleteMarker {
+		return new(MemoryFile), notExist("get version", path)
+	}
+
+	return fs.newFile(path, fs.files[path][i]), nil
+}
+
+// DeleteVersion permanently removes a single version from the history of the file at path.
+func (fs *MemoryFileSystem) DeleteVersion(path, id string) error {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	i := fs.find(path, id)
+	if i < 0 {
+		return notExist("delete version", path)
+	}
+
+	versions := fs.files[path]
+	fs.files[path] = append(versions[:i:i], versions[i+1:]...)
+	if len(fs.files[path]) == 0 {
+		delete(fs.files, path)
+	}
+
+	return nil
+}
+
+// RestoreVersion copies an older version of the file at path to become its newest version.
+func (fs *MemoryFileSystem) RestoreVersion(path, id string) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(MemoryFile), err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	i := fs.find(path, id)
+	if i < 0 || fs.files[path][i].deleteMarker {
+		return new(MemoryFile), notExist("restore version", path)
+	}
+
+	old := fs.files[path][i]
+	obj := &memoryObject{
+		content:     old.content,
+		contentType: old.contentType,
+		encoding:    old.encoding,
+		metadata:    old.metadata,
+	}
+	fs.push(path, obj)
+
+	return fs.newFile(path, obj), nil
+}
+
+// push stamps an object with a new version id and modification time and appends it to
+// the history of the file at path, the caller must hold the write lock.
+func (fs *MemoryFileSystem) push(path string, obj *memoryObject) {
+	fs.next++
+	obj.version = strconv.Itoa(fs.next)
+	obj.mod = fs.time.Now()
+
+	if fs.unversioned {
+		fs.files[path] = []*memoryObject{obj}
+		return
+	}
+
+	fs.files[path] = append(fs.files[path], obj)
+}
+
+// find returns the index of the version with the given id in the history of the file
+// at path, or -1 if it does not exist.
+func (fs *MemoryFileSystem) find(path, id string) int {
+	for i, obj := range fs.files[path] {
+		if obj.version == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// newFile wraps a stored object in a MemoryFile.
+func (fs *MemoryFileSystem) newFile(path string, obj *memoryObject) *MemoryFile {
+	return NewMemoryFile(obj.content, &MemoryFileInfo{
+		key:         path,
+		size:        int64(len(obj.content)),
+		mod:         obj.mod,
+		etag:        obj.etag(),
+		contentType: obj.contentType,
+		encoding:    obj.encoding,
+		checksum:    obj.checksum(),
+		version:     obj.version,
+		metadata:    obj.metadata,
+	}, fs)
+}
+
+// checksum returns the hex encoded md5 of the object contents.
+func (obj *memoryObject) checksum() string {
+	sum := md5.Sum(obj.content)
+	return hex.EncodeToString(sum[:])
+}
+
+// etag returns the quoted md5 of the object contents in the same format as s3.
+func (obj *memoryObject) etag() string {
+	return "\"" + obj.checksum() + "\""
+}
+
+// notExist builds an error for a file which could not be found that satisfies os.IsNotExist.
+func notExist(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: os.ErrNotExist}
+}
+
+// MemoryFile conforms to the File interface for contents which are held in memory.
+type MemoryFile struct {
+	r    *bytes.Reader
+	info *MemoryFileInfo
+	fs   FileSystem
+}
+
+// NewMemoryFile is a construct function which returns a File reading from contents.
+// the info is returned from Stat, and writes to the file are put to fs at the key of the info.
+func NewMemoryFile(contents []byte, info *MemoryFileInfo, fs FileSystem) *MemoryFile {
+	return &MemoryFile{
+		bytes.NewReader(contents),
+		info,
+		fs,
+	}
+}
+
+// Close method has no functionality but is used to conform to the interface.
+func (f *MemoryFile) Close() error {
+	return nil
+}
+
+// Stat returns the file info of the file.
+func (f *MemoryFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// Read delegates to the in memory reader.
+func (f *MemoryFile) Read(p []byte) (n int, err error) {
+	return f.r.Read(p)
+}
+
+// Seek delegates to the in memory reader.
+func (f *MemoryFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+// Write replaces the contents of the file by putting p to the file system the file came from.
+func (f *MemoryFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.info.Key())
+	return len(p), err
+}
+
+// MemoryFileInfo conforms to the FileInfo interface for files held in memory.
+type MemoryFileInfo struct {
+	key         string
+	size        int64
+	mod         time.Time
+	etag        string
+	contentType string
+	encoding    string
+	checksum    string
+	version     string
+	metadata    map[string]string
+}
+
+// Name gets the base name of the file.
+func (i *MemoryFileInfo) Name() string {
+	return path.Base(i.key)
+}
+
+// Key returns the full path the file is stored under.
+func (i *MemoryFileInfo) Key() string {
+	return i.key
+}
+
+// Size returns the length in bytes of the file.
+func (i *MemoryFileInfo) Size() int64 {
+	return i.size
+}
+
+// IsDir returns false as only files are held in memory.
+func (i *MemoryFileInfo) IsDir() bool {
+	return false
+}
+
+// Mode returns a os.ModePerm as the file is assumed perm.
+func (i *MemoryFileInfo) Mode() os.FileMode {
+	return os.ModePerm
+}
+
+// Sys underlying data source which should return nil.
+func (i *MemoryFileInfo) Sys() interface{} {
+	return nil
+}
+
+// ModTime returns modification time.
+func (i *MemoryFileInfo) ModTime() time.Time {
+	return i.mod
+}
+
+// ETag returns the entity tag of the file.
+func (i *MemoryFileInfo) ETag() string {
+	return i.etag
+}
+
+// ContentType returns the mime type of the file.
+func (i *MemoryFileInfo) ContentType() string {
+	return i.contentType
+}
+
+// ContentEncoding returns the encoding the file was put with.
+func (i *MemoryFileInfo) ContentEncoding() string {
+	return i.encoding
+}
+
+// Checksum returns the hex encoded md5 of the file contents.
+func (i *MemoryFileInfo) Checksum() string {
+	return i.checksum
+}
+
+// Version returns the version id of the file.
+func (i *MemoryFileInfo) Version() string {
+	return i.version
+}
+
+// Metadata returns the user defined metadata stored with the file.
+func (i *MemoryFileInfo) Metadata() map[string]string {
+	return i.metadata
+}
@@ -0,0 +1,163 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpMemoryFileSystem() (*MemoryFileSystem, *MockTime) {
+	timer := new(MockTime)
+	fs := NewMemoryFileSystem()
+	fs.time = timer
+
+	return fs, timer
+}
+
+func TestMemoryFileSystemPutThenGetReturnsContents(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	now := time.Now()
+	timer.On("Now").Return(now)
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "my path/file.txt")
+	assert.Nil(t, err)
+
+	file, err := fs.Get("my-path/file.txt")
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, []byte("contents"), b)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "file.txt", info.Name())
+	assert.Equal(t, int64(8), info.Size())
+	assert.Equal(t, now, info.ModTime())
+	assert.Equal(t, "98bf7d8c15784f0a3d63204441e1e2aa", info.Checksum())
+	assert.Equal(t, "\"98bf7d8c15784f0a3d63204441e1e2aa\"", info.ETag())
+	assert.Equal(t, "1", info.Version())
+}
+
+func TestMemoryFileSystemGetMissingFileReturnsNotExist(t *testing.T) {
+	fs, _ := setUpMemoryFileSystem()
+
+	_, err := fs.Get("missing.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMemoryFileSystemKeepsVersionHistory(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	first := time.Unix(100, 0)
+	second := time.Unix(200, 0)
+	timer.On("Now").Return(first).Once()
+	timer.On("Now").Return(second).Once()
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+
+	versions, err := fs.Versions("file.txt")
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "2", versions[0].ID)
+	assert.True(t, versions[0].IsLatest)
+	assert.Equal(t, second, versions[0].ModTime)
+	assert.Equal(t, "1", versions[1].ID)
+	assert.False(t, versions[1].IsLatest)
+
+	file, err := fs.GetVersion("file.txt", "1")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, []byte("one"), b)
+}
+
+func TestMemoryFileSystemRestoreVersionBecomesLatest(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	timer.On("Now").Return(time.Now())
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+
+	restored, err := fs.RestoreVersion("file.txt", "1")
+	assert.Nil(t, err)
+
+	info, _ := Stat(restored)
+	assert.Equal(t, "3", info.Version())
+
+	file, _ := fs.Get("file.txt")
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, []byte("one"), b)
+
+	versions, _ := fs.Versions("file.txt")
+	assert.Len(t, versions, 3)
+}
+
+func TestMemoryFileSystemDeleteVersionRemovesFromHistory(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	timer.On("Now").Return(time.Now())
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+
+	assert.Nil(t, fs.DeleteVersion("file.txt", "2"))
+
+	file, _ := fs.Get("file.txt")
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, []byte("one"), b)
+
+	assert.Nil(t, fs.DeleteVersion("file.txt", "1"))
+	_, err := fs.Get("file.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.True(t, os.IsNotExist(fs.DeleteVersion("file.txt", "1")))
+}
+
+func TestMemoryFileWritePutsNewVersion(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	timer.On("Now").Return(time.Now())
+
+	file, _ := fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	file.Write([]byte("two"))
+
+	latest, _ := fs.Get("file.txt")
+	b, _ := ioutil.ReadAll(latest)
+	assert.Equal(t, []byte("two"), b)
+}
+
+func TestMemoryFileSystemDeleteAddsDeleteMarker(t *testing.T) {
+	fs, timer := setUpMemoryFileSystem()
+	timer.On("Now").Return(time.Now())
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	assert.Nil(t, fs.Delete("file.txt"))
+
+	_, err := fs.Get("file.txt")
+	assert.True(t, IsNotExist(err))
+	assert.True(t, IsNotExist(fs.Delete("file.txt")))
+
+	versions, _ := fs.Versions("file.txt")
+	assert.True(t, versions[0].DeleteMarker)
+
+	_, err = fs.RestoreVersion("file.txt", versions[1].ID)
+	assert.Nil(t, err)
+	_, err = fs.Get("file.txt")
+	assert.Nil(t, err)
+}
+
+func TestMemoryFileSystemWithoutVersioningKeepsLatestOnly(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.SetVersioning(false)
+
+	fs.Put(bytes.NewReader([]byte("one")), "file.txt")
+	fs.Put(bytes.NewReader([]byte("two")), "file.txt")
+
+	versions, _ := fs.Versions("file.txt")
+	assert.Len(t, versions, 1)
+
+	assert.Nil(t, fs.Delete("file.txt"))
+
+	_, err := fs.Versions("file.txt")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,348 @@
+package gofile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Quorum is the number of replicas of a MirrorFileSystem which must succeed for a write to succeed.
+type Quorum int
+
+const (
+	// QuorumAll requires every replica to succeed.
+	QuorumAll Quorum = iota
+
+	// QuorumMajority requires more than half of the replicas to succeed.
+	QuorumMajority
+
+	// QuorumAny requires a single replica to succeed.
+	QuorumAny
+)
+
+// required returns the number of successes the quorum needs out of n replicas.
+func (q Quorum) required(n int) int {
+	switch q {
+	case QuorumAny:
+		return 1
+	case QuorumMajority:
+		return n/2 + 1
+	default:
+		return n
+	}
+}
+
+// RepairFunc is called by a MirrorFileSystem with the path of a file and the indexes of the
+// replicas which are missing it, either because a write to them failed or a read found it missing.
+type RepairFunc func(path string, replicas []int)
+
+// MirrorError is returned when too few replicas of a MirrorFileSystem succeed.
+type MirrorError struct {
+	Op   string
+	Path string
+
+	// Errs holds the error returned by each replica, nil for the replicas which succeeded.
+	Errs []error
+}
+
+// Error lists the error of each failed replica.
+func (e *MirrorError) Error() string {
+	var failed []string
+	for i, err := range e.Errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("replica %d: %v", i, err))
+		}
+	}
+
+	return fmt.Sprintf("gofile: %s %s did not reach quorum, %s", e.Op, e.Path, strings.Join(failed, ", "))
+}
+
+// MirrorFileSystem replicates files across several file systems. Writes are sent to every
+// replica concurrently and succeed once the quorum of replicas has succeeded, replicas which
+// were written are not rolled back if the quorum is not met. Reads are served by the first
+// replica, in the order given, which returns the file.
+type MirrorFileSystem struct {
+	replicas []FileSystem
+	quorum   Quorum
+	repair   RepairFunc
+}
+
+// NewMirrorFileSystem is a construct function which mirrors files across replicas with the given write quorum.
+func NewMirrorFileSystem(quorum Quorum, replicas ...FileSystem) *MirrorFileSystem {
+	return &MirrorFileSystem{
+		replicas: replicas,
+		quorum:   quorum,
+	}
+}
+
+// SetRepairFunc sets the hook called when a write reaches quorum but fails on some replicas,
+// or a read finds a file missing from a replica. Repair can be used to copy the file back
+// to the replicas, for example:
+//
+//	fs.SetRepairFunc(func(path string, replicas []int) {
+//		go fs.Repair(path, replicas...)
+//	})
+func (m *MirrorFileSystem) SetRepairFunc(fn RepairFunc) {
+	m.repair = fn
+}
+
+// Put writes the file to every replica, see PutWithOptions.
+func (m *MirrorFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return m.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file to every replica concurrently, returning the file from the
+// first replica which succeeded once the quorum is met.
+func (m *MirrorFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	content, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, len(m.replicas))
+	errs := m.each(func(i int, replica FileSystem) error {
+		file, err := PutWithOptions(replica, bytes.NewReader(content), path, opts)
+		files[i] = file
+		return err
+	})
+
+	err = m.check("put", path, errs)
+
+	returned := -1
+	for i := range files {
+		if err == nil && errs[i] == nil {
+			returned = i
+			break
+		}
+	}
+
+	// only one file is returned, the others are closed so their descriptors are not leaked.
+	for i, file := range files {
+		if i != returned && file != nil {
+			file.Close()
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if returned < 0 {
+		return nil, &MirrorError{"put", path, errs}
+	}
+
+	return m.newFile(files[returned], path), nil
+}
+
+// Get returns the file from the first replica holding it, falling back to the next replica
+// when one fails.
+func (m *MirrorFileSystem) Get(path string) (File, error) {
+	var missing []int
+	var lastErr error
+
+	for i, replica := range m.replicas {
+		file, err := replica.Get(path)
+		if err == nil {
+			if len(missing) > 0 && m.repair != nil {
+				m.repair(path, missing)
+			}
+			return m.newFile(file, path), nil
+		}
+
+		if IsNotExist(err) {
+			missing = append(missing, i)
+		} else {
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	return nil, notExist("get", path)
+}
+
+// Delete removes the file from every replica concurrently, a replica which does not hold
+// the file counts as a success unless no replica holds it.
+func (m *MirrorFileSystem) Delete(path string) error {
+	missing := 0
+	errs := m.each(func(i int, replica FileSystem) error {
+		return Delete(replica, path)
+	})
+
+	for i, err := range errs {
+		if IsNotExist(err) {
+			errs[i] = nil
+			missing++
+		}
+	}
+
+	if missing == len(m.replicas) {
+		return notExist("delete", path)
+	}
+
+	return m.check("delete", path, errs)
+}
+
+// List returns the paths starting with prefix held by any replica, an error is only
+// returned if every replica fails to list.
+func (m *MirrorFileSystem) List(prefix string) ([]string, error) {
+	seen := make(map[string]bool)
+	var lastErr error
+	listed := false
+
+	for _, replica := range m.replicas {
+		paths, err := List(replica, prefix)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		listed = true
+		for _, p := range paths {
+			seen[p] = true
+		}
+	}
+
+	if !listed {
+		return nil, lastErr
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Repair copies the file at path from a replica holding it to the given replicas, or to
+// every other replica if none are given. Replicas outside of those given are preferred as
+// the source of the copy.
+func (m *MirrorFileSystem) Repair(path string, replicas ...int) error {
+	if len(replicas) == 0 {
+		for i := range m.replicas {
+			replicas = append(replicas, i)
+		}
+	}
+
+	targets := make(map[int]bool)
+	for _, i := range replicas {
+		targets[i] = true
+	}
+
+	source := -1
+	var file File
+	for pass := 0; pass < 2 && file == nil; pass++ {
+		for i, replica := range m.replicas {
+			if pass == 0 && targets[i] {
+				continue
+			}
+
+			if f, err := replica.Get(path); err == nil {
+				source, file = i, f
+				break
+			}
+		}
+	}
+
+	if file == nil {
+		return notExist("repair", path)
+	}
+	defer file.Close()
+
+	info, err := Stat(file)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	opts := putOptionsFromInfo(info)
+	for i := range targets {
+		if i == source {
+			continue
+		}
+
+		copied, err := PutWithOptions(m.replicas[i], bytes.NewReader(content), path, opts)
+		if err != nil {
+			return err
+		}
+		copied.Close()
+	}
+
+	return nil
+}
+
+// each calls fn on every replica concurrently and returns the error from each.
+func (m *MirrorFileSystem) each(fn func(i int, replica FileSystem) error) []error {
+	errs := make([]error, len(m.replicas))
+
+	var wg sync.WaitGroup
+	for i, replica := range m.replicas {
+		wg.Add(1)
+		go func(i int, replica FileSystem) {
+			defer wg.Done()
+			errs[i] = fn(i, replica)
+		}(i, replica)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// check returns a MirrorError if too few replicas succeeded, and calls the repair hook with
+// the failed replicas if the quorum was met.
+func (m *MirrorFileSystem) check(op, path string, errs []error) error {
+	var failed []int
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+
+	if len(m.replicas)-len(failed) < m.quorum.required(len(m.replicas)) {
+		return &MirrorError{op, path, errs}
+	}
+
+	if len(failed) > 0 && op == "put" && m.repair != nil {
+		m.repair(path, failed)
+	}
+
+	return nil
+}
+
+// newFile wraps a file from a replica so that writes to it are mirrored.
+func (m *MirrorFileSystem) newFile(file File, path string) *MirrorFile {
+	return &MirrorFile{
+		file,
+		path,
+		m,
+	}
+}
+
+// MirrorFile is a File read from a MirrorFileSystem.
+type MirrorFile struct {
+	File
+	path string
+	fs   *MirrorFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *MirrorFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to every replica.
+func (f *MirrorFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
@@ -0,0 +1,152 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMirrorFileSystemPutWritesEveryReplica(t *testing.T) {
+	first := NewMemoryFileSystem()
+	second := NewMemoryFileSystem()
+	fs := NewMirrorFileSystem(QuorumAll, first, second)
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, first, "file.txt"))
+	assert.Equal(t, "contents", readAll(t, second, "file.txt"))
+}
+
+func TestMirrorFileSystemPutQuorum(t *testing.T) {
+	down := new(MockFileSystem)
+	down.On("Put", mock.Anything, "file.txt").Return(nil, errors.New("down"))
+
+	fs := NewMirrorFileSystem(QuorumAll, NewMemoryFileSystem(), down)
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.IsType(t, &MirrorError{}, err)
+	assert.Equal(t, []error{nil, errors.New("down")}, err.(*MirrorError).Errs)
+
+	fs = NewMirrorFileSystem(QuorumMajority, NewMemoryFileSystem(), NewMemoryFileSystem(), down)
+	_, err = fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+
+	fs = NewMirrorFileSystem(QuorumMajority, NewMemoryFileSystem(), down, down)
+	_, err = fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.NotNil(t, err)
+
+	fs = NewMirrorFileSystem(QuorumAny, NewMemoryFileSystem(), down, down)
+	_, err = fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+}
+
+func TestMirrorFileSystemPutCallsRepairForFailedReplicas(t *testing.T) {
+	down := new(MockFileSystem)
+	down.On("Put", mock.Anything, "file.txt").Return(nil, errors.New("down"))
+
+	fs := NewMirrorFileSystem(QuorumAny, NewMemoryFileSystem(), down)
+
+	var repaired []int
+	fs.SetRepairFunc(func(path string, replicas []int) {
+		assert.Equal(t, "file.txt", path)
+		repaired = replicas
+	})
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, repaired)
+}
+
+func TestMirrorFileSystemGetFallsBackAndRepairs(t *testing.T) {
+	down := new(MockFileSystem)
+	down.On("Get", "file.txt").Return(nil, errors.New("down"))
+	empty := NewMemoryFileSystem()
+	full := NewMemoryFileSystem()
+	full.Put(bytes.NewReader([]byte("contents")), "file.txt")
+
+	fs := NewMirrorFileSystem(QuorumAll, down, empty, full)
+	fs.SetRepairFunc(func(path string, replicas []int) {
+		assert.Equal(t, []int{1}, replicas)
+		assert.Nil(t, fs.Repair(path, replicas...))
+	})
+
+	assert.Equal(t, "contents", readAll(t, fs, "file.txt"))
+	assert.Equal(t, "contents", readAll(t, empty, "file.txt"))
+
+	_, err := NewMirrorFileSystem(QuorumAll, empty, full).Get("missing.txt")
+	assert.True(t, IsNotExist(err))
+}
+
+func TestMirrorFileSystemRepairKeepsStoredOptions(t *testing.T) {
+	source := NewMemoryFileSystem()
+	target := NewMemoryFileSystem()
+	source.PutWithOptions(bytes.NewReader([]byte("{}")), "data.bin", PutOptions{
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"owner": "me"},
+	})
+
+	assert.Nil(t, NewMirrorFileSystem(QuorumAny, source, target).Repair("data.bin", 1))
+
+	info, err := target.Stat("data.bin")
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", info.ContentType())
+	assert.Equal(t, "gzip", info.(*MemoryFileInfo).ContentEncoding())
+	assert.Equal(t, map[string]string{"owner": "me"}, info.Metadata())
+}
+
+func TestMirrorFileSystemDeleteAndList(t *testing.T) {
+	first := NewMemoryFileSystem()
+	second := NewMemoryFileSystem()
+	first.Put(bytes.NewReader([]byte("a")), "a.txt")
+	second.Put(bytes.NewReader([]byte("a")), "a.txt")
+	second.Put(bytes.NewReader([]byte("b")), "b.txt")
+
+	fs := NewMirrorFileSystem(QuorumAll, first, second)
+
+	paths, err := fs.List("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, paths)
+
+	assert.Nil(t, fs.Delete("b.txt"))
+	assert.True(t, IsNotExist(fs.Delete("b.txt")))
+
+	paths, _ = fs.List("")
+	assert.Equal(t, []string{"a.txt"}, paths)
+}
+
+func TestMirrorFileSystemPutClosesFilesNotReturned(t *testing.T) {
+	returned := new(MockFile)
+	other := new(MockFile)
+	other.On("Close").Return(nil)
+
+	first := new(MockFileSystem)
+	first.On("Put", mock.Anything, "file.txt").Return(returned, nil)
+	second := new(MockFileSystem)
+	second.On("Put", mock.Anything, "file.txt").Return(other, nil)
+
+	fs := NewMirrorFileSystem(QuorumAll, first, second)
+	file, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, returned, file.(*MirrorFile).File)
+	other.AssertCalled(t, "Close")
+	returned.AssertNotCalled(t, "Close")
+
+	failed := new(MockFile)
+	failed.On("Close").Return(nil)
+	down := new(MockFileSystem)
+	down.On("Put", mock.Anything, "file.txt").Return(failed, errors.New("down"))
+	other = new(MockFile)
+	other.On("Close").Return(nil)
+	second = new(MockFileSystem)
+	second.On("Put", mock.Anything, "file.txt").Return(other, nil)
+
+	fs = NewMirrorFileSystem(QuorumAll, down, second)
+	_, err = fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.IsType(t, &MirrorError{}, err)
+	failed.AssertCalled(t, "Close")
+	other.AssertCalled(t, "Close")
+}
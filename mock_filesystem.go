@@ -3,6 +3,7 @@ package gofile
 import (
 	"io"
 	os "os"
+	"path/filepath"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -28,6 +29,48 @@ func (_m *MockCoreFs) MkdirAll(path string, perm os.FileMode) error {
 	return r0
 }
 
+// Remove provides a mock function with given fields: name.
+func (_m *MockCoreFs) Remove(name string) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Rename provides a mock function with given fields: oldpath, newpath.
+func (_m *MockCoreFs) Rename(oldpath string, newpath string) error {
+	ret := _m.Called(oldpath, newpath)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(oldpath, newpath)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Walk provides a mock function with given fields: root, fn.
+func (_m *MockCoreFs) Walk(root string, fn filepath.WalkFunc) error {
+	ret := _m.Called(root, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, filepath.WalkFunc) error); ok {
+		r0 = rf(root, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: name.
 func (_m *MockCoreFs) Create(name string) (File, error) {
 	ret := _m.Called(name)
@@ -403,6 +446,78 @@ func (_m *MockFileInfo) Sys() interface{} {
 	return r0
 }
 
+// ETag provides a mock function.
+func (_m *MockFileInfo) ETag() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// ContentType provides a mock function.
+func (_m *MockFileInfo) ContentType() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// Checksum provides a mock function.
+func (_m *MockFileInfo) Checksum() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// Version provides a mock function.
+func (_m *MockFileInfo) Version() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// Metadata provides a mock function.
+func (_m *MockFileInfo) Metadata() map[string]string {
+	ret := _m.Called()
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func() map[string]string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	return r0
+}
+
 // S3Caller is an autogenerated mock type for the S3Caller type.
 type MockS3Caller struct {
 	mock.Mock
@@ -431,6 +546,27 @@ func (_m *MockS3Caller) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput
 	return r0, r1
 }
 
+// PresignGetObject provides a mock function with given fields: input, expires.
+func (_m *MockS3Caller) PresignGetObject(input *s3.GetObjectInput, expires time.Duration) (string, error) {
+	ret := _m.Called(input, expires)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(*s3.GetObjectInput, time.Duration) string); ok {
+		r0 = rf(input, expires)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.GetObjectInput, time.Duration) error); ok {
+		r1 = rf(input, expires)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewSvc provides a mock function with given fields: cfgs.
 func (_m *MockS3Caller) NewSvc(cfgs ...*aws.Config) S3Caller {
 	ret := _m.Called(cfgs)
@@ -469,3 +605,210 @@ func (_m *MockS3Caller) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput
 
 	return r0, r1
 }
+
+// ListObjectVersions provides a mock function with given fields: input.
+func (_m *MockS3Caller) ListObjectVersions(input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.ListObjectVersionsOutput
+	if rf, ok := ret.Get(0).(func(*s3.ListObjectVersionsInput) *s3.ListObjectVersionsOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.ListObjectVersionsOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.ListObjectVersionsInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HeadObject provides a mock function with given fields: input.
+func (_m *MockS3Caller) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.HeadObjectOutput
+	if rf, ok := ret.Get(0).(func(*s3.HeadObjectInput) *s3.HeadObjectOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.HeadObjectOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.HeadObjectInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteObject provides a mock function with given fields: input.
+func (_m *MockS3Caller) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.DeleteObjectOutput
+	if rf, ok := ret.Get(0).(func(*s3.DeleteObjectInput) *s3.DeleteObjectOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.DeleteObjectOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.DeleteObjectInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CopyObject provides a mock function with given fields: input.
+func (_m *MockS3Caller) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.CopyObjectOutput
+	if rf, ok := ret.Get(0).(func(*s3.CopyObjectInput) *s3.CopyObjectOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.CopyObjectOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.CopyObjectInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateMultipartUpload provides a mock function with given fields: input.
+func (_m *MockS3Caller) CreateMultipartUpload(input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.CreateMultipartUploadOutput
+	if rf, ok := ret.Get(0).(func(*s3.CreateMultipartUploadInput) *s3.CreateMultipartUploadOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.CreateMultipartUploadOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.CreateMultipartUploadInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UploadPart provides a mock function with given fields: input.
+func (_m *MockS3Caller) UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.UploadPartOutput
+	if rf, ok := ret.Get(0).(func(*s3.UploadPartInput) *s3.UploadPartOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.UploadPartOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.UploadPartInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CompleteMultipartUpload provides a mock function with given fields: input.
+func (_m *MockS3Caller) CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.CompleteMultipartUploadOutput
+	if rf, ok := ret.Get(0).(func(*s3.CompleteMultipartUploadInput) *s3.CompleteMultipartUploadOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.CompleteMultipartUploadOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.CompleteMultipartUploadInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AbortMultipartUpload provides a mock function with given fields: input.
+func (_m *MockS3Caller) AbortMultipartUpload(input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.AbortMultipartUploadOutput
+	if rf, ok := ret.Get(0).(func(*s3.AbortMultipartUploadInput) *s3.AbortMultipartUploadOutput); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.AbortMultipartUploadOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.AbortMultipartUploadInput) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListObjectsV2 provides a mock function with given fields: input.
+func (_m *MockS3Caller) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	ret := _m.Called(input)
+
+	var r0 *s3.ListObjectsV2Output
+	if rf, ok := ret.Get(0).(func(*s3.ListObjectsV2Input) *s3.ListObjectsV2Output); ok {
+		r0 = rf(input)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*s3.ListObjectsV2Output)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*s3.ListObjectsV2Input) error); ok {
+		r1 = rf(input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,15 +1,30 @@
 package gofile
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 var errorIncorrectPath = errors.New("The path given was provided in the incorrect format")
 
+// checksumSuffix is appended to the path of a file to give the path its checksum is stored at.
+const checksumSuffix = ".gofile-checksum"
+
+// tempSuffix ends the name of the temporary file PutStream writes to before it replaces a file.
+const tempSuffix = ".gofile-tmp"
+
 // CoreFs interface defines a wrapper around core filesystem so that it can be extended and mocked.
 type CoreFs interface {
 	Open(name string) (File, error)
@@ -17,6 +32,9 @@ type CoreFs interface {
 	Stat(name string) (os.FileInfo, error)
 	Copy(dst io.Writer, src io.Reader) (int64, error)
 	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	Walk(root string, fn filepath.WalkFunc) error
 }
 
 // osFS implements coreFs using the local disk.
@@ -37,21 +55,117 @@ func (osFS) Copy(dst io.Writer, src io.Reader) (int64, error) { return io.Copy(d
 // MkdirAll calls the default os.MkdirAll.
 func (osFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
 
+// Remove calls the default os.Remove.
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+// Rename calls the default os.Rename.
+func (osFS) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+// Walk calls the default filepath.Walk.
+func (osFS) Walk(root string, fn filepath.WalkFunc) error { return filepath.Walk(root, fn) }
+
 // OSFileSystem implements the FileSystem interface by calling the CoreFs.
 type OSFileSystem struct {
-	os CoreFs
+	os       CoreFs
+	checksum ChecksumAlgorithm
+	verify   bool
+	policy   PathPolicy
 }
 
 // NewOSFileSystem is a construct function that returns a pointer to a OSFileSystem.
 func NewOSFileSystem() *OSFileSystem {
 	return &OSFileSystem{
-		&osFS{},
+		os:     &osFS{},
+		policy: PathPolicy{Sanitize: true},
 	}
 }
 
+// SetChecksum sets the algorithm used to checksum files as they are put, the checksum is
+// stored alongside the file. When verify is true Get checks files against their stored
+// checksum and returns ErrChecksumMismatch if they differ.
+func (fs *OSFileSystem) SetChecksum(algo ChecksumAlgorithm, verify bool) {
+	fs.checksum = algo
+	fs.verify = verify
+}
+
+// SetPathPolicy sets the policy paths are cleaned with before they are used on disk.
+func (fs *OSFileSystem) SetPathPolicy(policy PathPolicy) {
+	fs.policy = policy
+}
+
+// CleanPath returns the key path is stored at under the path policy.
+func (fs *OSFileSystem) CleanPath(path string) (string, error) {
+	return fs.policy.Clean(path)
+}
+
 // Put creates a file with the given location, creating the directories as needed.
 func (fs *OSFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
-	path = SanitizePath(path)
+	path, err := fs.prepare(path)
+	if err != nil {
+		return new(os.File), err
+	}
+
+	file, err := fs.os.Create(path)
+	if err != nil {
+		return file, err
+	}
+
+	sum, err := fs.copy(file, src)
+	if err != nil {
+		return file, err
+	}
+
+	if sum != nil {
+		if err := fs.writeChecksum(path, sum); err != nil {
+			return file, err
+		}
+	}
+
+	return fs.newFile(file, path), nil
+}
+
+// PutStream creates a file like Put from a reader which cannot seek, copying it to disk as
+// it is read. The contents are written to a temporary file in the same directory which only
+// replaces the file at path once the whole of src has been read, so a reader which fails
+// part way leaves the existing file intact. The options are not supported on disk and are ignored.
+func (fs *OSFileSystem) PutStream(src io.Reader, path string, opts PutOptions) (File, error) {
+	path, err := fs.prepare(path)
+	if err != nil {
+		return new(os.File), err
+	}
+
+	temp := path + "." + strconv.FormatUint(rand.Uint64(), 36) + tempSuffix
+	file, err := fs.os.Create(temp)
+	if err != nil {
+		return new(os.File), err
+	}
+
+	sum, err := fs.copy(file, src)
+	if err == nil {
+		err = fs.os.Rename(temp, path)
+	}
+	if err != nil {
+		file.Close()
+		fs.os.Remove(temp)
+		return new(os.File), err
+	}
+
+	if sum != nil {
+		if err := fs.writeChecksum(path, sum); err != nil {
+			return file, err
+		}
+	}
+
+	return fs.newFile(file, path), nil
+}
+
+// prepare cleans path for use on disk and creates its directory.
+func (fs *OSFileSystem) prepare(path string) (string, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return "", err
+	}
+
 	r := regexp.MustCompile("(.+\\/)*(.+)\\.(.+)$")
 	t := regexp.MustCompile("^\\/")
 
@@ -64,25 +178,254 @@ func (fs *OSFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
 	matches := r.FindStringSubmatch(path)
 
 	if len(matches) < 1 {
-		return new(os.File), errorIncorrectPath
+		return "", errorIncorrectPath
 	}
 
 	fs.os.MkdirAll(matches[1], 0755)
 
-	file, err := fs.os.Create(path)
+	return path, nil
+}
+
+// copy copies src to file, returning the checksum of the contents when checksums are enabled.
+func (fs *OSFileSystem) copy(file File, src io.Reader) ([]byte, error) {
+	h := fs.checksum.New()
+	var reader io.Reader = src
+	if h != nil {
+		reader = io.TeeReader(src, h)
+	}
+
+	if _, err := fs.os.Copy(file, reader); err != nil {
+		return nil, err
+	}
+
+	if h == nil {
+		return nil, nil
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Get returns a file from the core os.
+func (fs *OSFileSystem) Get(key string) (File, error) {
+	key, err := fs.policy.Clean(key)
 	if err != nil {
-		return file, err
+		return nil, err
 	}
 
-	_, err = fs.os.Copy(file, src)
+	file, err := fs.os.Open(key)
 	if err != nil {
 		return file, err
 	}
 
-	return file, nil
+	if fs.verify {
+		if err := fs.verifyChecksum(file, key); err != nil {
+			file.Close()
+			return file, err
+		}
+	}
+
+	return fs.newFile(file, key), nil
 }
 
-// Get returns a file from the core os.
-func (fs *OSFileSystem) Get(key string) (File, error) {
-	return fs.os.Open(key)
+// Stat returns the info of the file at key without opening it.
+func (fs *OSFileSystem) Stat(key string) (FileInfo, error) {
+	key, err := fs.policy.Clean(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fs.os.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OSFileInfo{
+		info,
+		key,
+		fs.os,
+	}, nil
+}
+
+// Delete removes the file at key from the core os along with its stored checksum.
+func (fs *OSFileSystem) Delete(key string) error {
+	key, err := fs.policy.Clean(key)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.os.Remove(key); err != nil {
+		return err
+	}
+
+	if fs.checksum != ChecksumNone {
+		fs.os.Remove(key + checksumSuffix)
+	}
+
+	return nil
+}
+
+// List walks the directory of prefix and returns the paths of every file starting with prefix,
+// stored checksums and the temporary files of unfinished puts are left out.
+func (fs *OSFileSystem) List(prefix string) ([]string, error) {
+	prefix, err := fs.policy.cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		root = filepath.Clean(prefix)
+	}
+
+	var paths []string
+	err = fs.os.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() || strings.HasSuffix(path, checksumSuffix) || strings.HasSuffix(path, tempSuffix) {
+			return nil
+		}
+
+		path = filepath.ToSlash(path)
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	sort.Strings(paths)
+	return paths, err
+}
+
+// writeChecksum stores the checksum of the file at path alongside it.
+func (fs *OSFileSystem) writeChecksum(path string, sum []byte) error {
+	file, err := fs.os.Create(path + checksumSuffix)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write([]byte(formatChecksum(fs.checksum, sum)))
+	return err
+}
+
+// verifyChecksum checks the contents of a file against the checksum stored alongside it and
+// rewinds the file. Files without a stored checksum are not checked.
+func (fs *OSFileSystem) verifyChecksum(file File, path string) error {
+	stored, err := fs.os.Open(path + checksumSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer stored.Close()
+
+	b, err := ioutil.ReadAll(stored)
+	if err != nil {
+		return err
+	}
+
+	algo, sum, err := parseChecksum(string(b))
+	if err != nil {
+		return err
+	}
+
+	h := algo.New()
+	if h == nil {
+		return errors.New("gofile: unknown checksum algorithm " + string(algo))
+	}
+
+	if _, err := fs.os.Copy(h, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// newFile wraps a file from the core os so that its Stat returns a FileInfo.
+func (fs *OSFileSystem) newFile(file File, path string) *OSFile {
+	return &OSFile{
+		file,
+		path,
+		fs.os,
+	}
+}
+
+// OSFile wraps a File opened on the core os, all calls are delegated to the embedded
+// File apart from Stat which returns an OSFileInfo.
+type OSFile struct {
+	File
+	path string
+	os   CoreFs
+}
+
+// Stat returns the file info of the underlying file wrapped in an OSFileInfo.
+func (f *OSFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return info, err
+	}
+
+	return &OSFileInfo{
+		info,
+		f.path,
+		f.os,
+	}, nil
+}
+
+// OSFileInfo conforms to the FileInfo interface for files on the core os.
+type OSFileInfo struct {
+	os.FileInfo
+	path string
+	os   CoreFs
+}
+
+// ETag returns an entity tag built from the modification time and size of the file.
+func (i *OSFileInfo) ETag() string {
+	return fmt.Sprintf("\"%x-%x\"", i.ModTime().UnixNano(), i.Size())
+}
+
+// ContentType returns the mime type guessed from the file extension.
+func (i *OSFileInfo) ContentType() string {
+	return GetMIMETypeFromPath(i.path)
+}
+
+// Checksum reads the file from disk and returns the hex encoded md5 of its contents.
+// an empty string is returned if the file cannot be read.
+func (i *OSFileInfo) Checksum() string {
+	file, err := i.os.Open(i.path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	h := md5.New()
+	if _, err := i.os.Copy(h, file); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Version returns an empty string as files on disk are not versioned.
+func (i *OSFileInfo) Version() string {
+	return ""
+}
+
+// Metadata returns nil as files on disk hold no metadata.
+func (i *OSFileInfo) Metadata() map[string]string {
+	return nil
 }
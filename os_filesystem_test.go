@@ -1,11 +1,18 @@
 package gofile
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 type MockReader struct {
@@ -30,7 +37,7 @@ func TestOsFileSystemCopiesBytesToCreatedFileWithRelativePath(t *testing.T) {
 	mockFile := new(MockFile)
 
 	fs := OSFileSystem{
-		corefs,
+		os: corefs,
 	}
 
 	corefs.On("MkdirAll", "./sys/", os.FileMode(0755)).Return(nil)
@@ -39,7 +46,7 @@ func TestOsFileSystemCopiesBytesToCreatedFileWithRelativePath(t *testing.T) {
 
 	file, err := fs.Put(src, path)
 
-	assert.Equal(t, mockFile, file)
+	assert.Equal(t, &OSFile{mockFile, "./" + path, corefs}, file)
 	assert.Nil(t, err)
 }
 
@@ -51,7 +58,7 @@ func TestOsFileSystemCopiesBytesToCreatedFileWithAbsPath(t *testing.T) {
 	mockFile := new(MockFile)
 
 	fs := OSFileSystem{
-		corefs,
+		os: corefs,
 	}
 
 	corefs.On("Create", path).Return(mockFile, nil)
@@ -60,7 +67,7 @@ func TestOsFileSystemCopiesBytesToCreatedFileWithAbsPath(t *testing.T) {
 
 	file, err := fs.Put(src, path)
 
-	assert.Equal(t, mockFile, file)
+	assert.Equal(t, &OSFile{mockFile, path, corefs}, file)
 	assert.Nil(t, err)
 }
 
@@ -73,7 +80,7 @@ func TestOsFileSystemCreateErrorPassedBack(t *testing.T) {
 	mockFile := new(MockFile)
 
 	fs := OSFileSystem{
-		corefs,
+		os: corefs,
 	}
 
 	corefs.On("MkdirAll", "./sys/", os.FileMode(0755)).Return(nil)
@@ -96,7 +103,7 @@ func TestOsFileSystemCopyErrorPassedBack(t *testing.T) {
 	mockFile := new(MockFile)
 
 	fs := OSFileSystem{
-		corefs,
+		os: corefs,
 	}
 
 	corefs.On("MkdirAll", "./sys/", os.FileMode(0755)).Return(nil)
@@ -116,8 +123,181 @@ func TestOsFileSystemPutReturnsErrorPathIncorrect(t *testing.T) {
 	corefs := new(MockCoreFs)
 
 	fs := OSFileSystem{
-		corefs,
+		os: corefs,
 	}
 	_, err := fs.Put(src, path)
 	assert.Equal(t, errorIncorrectPath, err)
 }
+
+func TestOsFileStatReturnsFileInfo(t *testing.T) {
+	path := "sys/test.png"
+
+	corefs := new(MockCoreFs)
+	mockFile := new(MockFile)
+	info := new(MockFileInfo)
+
+	fs := OSFileSystem{
+		os: corefs,
+	}
+
+	mod := time.Unix(0, 255)
+	info.On("ModTime").Return(mod)
+	info.On("Size").Return(int64(16))
+	mockFile.On("Stat").Return(info, nil)
+	corefs.On("Open", path).Return(mockFile, nil)
+
+	file, err := fs.Get(path)
+	assert.Nil(t, err)
+
+	stat, err := Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "\"ff-10\"", stat.ETag())
+	assert.Equal(t, "image/png", stat.ContentType())
+	assert.Equal(t, "", stat.Version())
+}
+
+func TestOsFileInfoChecksumReadsFile(t *testing.T) {
+	path := "sys/test.txt"
+
+	corefs := new(MockCoreFs)
+	mockFile := new(MockFile)
+
+	corefs.On("Open", path).Return(mockFile, nil)
+	corefs.On("Copy", mock.Anything, mockFile).Run(func(args mock.Arguments) {
+		args.Get(0).(io.Writer).Write([]byte("contents"))
+	}).Return(int64(8), nil)
+	mockFile.On("Close").Return(nil)
+
+	info := &OSFileInfo{nil, path, corefs}
+	assert.Equal(t, "98bf7d8c15784f0a3d63204441e1e2aa", info.Checksum())
+}
+
+func TestOsFileSystemDeleteRemovesFile(t *testing.T) {
+	corefs := new(MockCoreFs)
+	fs := OSFileSystem{
+		os: corefs,
+	}
+
+	corefs.On("Remove", "sys/test.png").Return(nil)
+
+	assert.Nil(t, fs.Delete("sys/test.png"))
+	corefs.AssertExpectations(t)
+}
+
+func copyArgs(args mock.Arguments) {
+	io.Copy(args.Get(0).(io.Writer), args.Get(1).(io.Reader))
+}
+
+func TestOsFileSystemPutStoresChecksum(t *testing.T) {
+	path := "/sys/test.txt"
+	src := bytes.NewReader([]byte("contents"))
+
+	corefs := new(MockCoreFs)
+	mockFile := new(MockFile)
+	sumFile := new(MockFile)
+
+	fs := OSFileSystem{
+		os: corefs,
+	}
+	fs.SetChecksum(ChecksumSHA256, false)
+
+	sum := []byte("SHA256 d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8\n")
+
+	corefs.On("MkdirAll", "/sys/", os.FileMode(0755)).Return(nil)
+	corefs.On("Create", path).Return(mockFile, nil)
+	corefs.On("Copy", mockFile, mock.Anything).Run(copyArgs).Return(int64(8), nil)
+	mockFile.On("Write", []byte("contents")).Return(8, nil)
+	corefs.On("Create", path+checksumSuffix).Return(sumFile, nil)
+	sumFile.On("Write", sum).Return(len(sum), nil)
+	sumFile.On("Close").Return(nil)
+
+	_, err := fs.Put(src, path)
+	assert.Nil(t, err)
+	sumFile.AssertExpectations(t)
+}
+
+func TestOsFileSystemGetVerifiesChecksum(t *testing.T) {
+	path := "sys/test.txt"
+	sum := []byte("MD5 98bf7d8c15784f0a3d63204441e1e2aa\n")
+
+	for content, expected := range map[string]error{"contents": nil, "tampered": ErrChecksumMismatch} {
+		corefs := new(MockCoreFs)
+		fs := OSFileSystem{
+			os: corefs,
+		}
+		fs.SetChecksum(ChecksumMD5, true)
+
+		file := NewMemoryFile([]byte(content), new(MemoryFileInfo), nil)
+		corefs.On("Open", path).Return(file, nil)
+		corefs.On("Open", path+checksumSuffix).Return(NewMemoryFile(sum, new(MemoryFileInfo), nil), nil)
+		corefs.On("Copy", mock.Anything, file).Run(copyArgs).Return(int64(8), nil)
+
+		_, err := fs.Get(path)
+		assert.Equal(t, expected, err, content)
+	}
+}
+
+func TestOsFileSystemGetSkipsFilesWithoutChecksum(t *testing.T) {
+	path := "sys/test.txt"
+
+	corefs := new(MockCoreFs)
+	fs := OSFileSystem{
+		os: corefs,
+	}
+	fs.SetChecksum(ChecksumMD5, true)
+
+	file := NewMemoryFile([]byte("contents"), new(MemoryFileInfo), nil)
+	corefs.On("Open", path).Return(file, nil)
+	corefs.On("Open", path+checksumSuffix).Return(nil, os.ErrNotExist)
+
+	_, err := fs.Get(path)
+	assert.Nil(t, err)
+}
+
+func TestOsFileSystemListWalksPrefixDirectory(t *testing.T) {
+	corefs := new(MockCoreFs)
+	fs := OSFileSystem{
+		os: corefs,
+	}
+
+	dir := new(MockFileInfo)
+	dir.On("IsDir").Return(true)
+	file := new(MockFileInfo)
+	file.On("IsDir").Return(false)
+
+	corefs.On("Walk", "sys", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		fn := args.Get(1).(filepath.WalkFunc)
+		fn("sys", dir, nil)
+		fn("sys/test.png", file, nil)
+		fn("sys/test.png"+checksumSuffix, file, nil)
+		fn("sys/other.png", file, nil)
+		fn("sys/nested/test.txt", file, nil)
+	})
+
+	paths, err := fs.List("sys/te")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sys/test.png"}, paths)
+
+	corefs.AssertExpectations(t)
+}
+
+func TestOsFileSystemPutStreamKeepsOldContentsOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gofile-os")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fs := NewOSFileSystem()
+	path := filepath.ToSlash(filepath.Join(dir, "a.json"))
+
+	_, err = PutStream(fs, NewBase64Decoder(strings.NewReader("eyJhIjoxfQ==")), path, PutOptions{})
+	assert.Nil(t, err)
+
+	_, err = PutStream(fs, NewBase64Decoder(strings.NewReader("eyJh!IjoxfQ==")), path, PutOptions{})
+	assert.NotNil(t, err)
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"a":1}`, string(b))
+
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,260 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix is prepended to the base name of a file to give the path of the marker
+// recording its deletion in the upper layer of an OverlayFileSystem. Base names starting
+// with it are reserved and cannot be put through the overlay.
+const whiteoutPrefix = ".wh."
+
+// OverlayFileSystem layers a writable FileSystem over any number of read only ones.
+// Files are read from the first layer holding them starting with the upper layer,
+// while every write goes to the upper layer so the lower layers are never changed.
+//
+// Deleting a file which exists in a lower layer records a whiteout marker in the upper
+// layer which hides the file from the layers below until it is put again. Whiteouts are
+// named after the file with a .wh. prefix on its base name, so putting such names through
+// the overlay fails with ErrInvalidPath.
+type OverlayFileSystem struct {
+	upper  FileSystem
+	lowers []FileSystem
+}
+
+// NewOverlayFileSystem is a construct function which layers upper over lowers,
+// lowers are searched in the order given.
+func NewOverlayFileSystem(upper FileSystem, lowers ...FileSystem) *OverlayFileSystem {
+	return &OverlayFileSystem{
+		upper,
+		lowers,
+	}
+}
+
+// Put writes the file to the upper layer, removing any whiteout recorded for it.
+func (o *OverlayFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	if _, ok := whiteoutOf(path); ok {
+		return nil, ErrInvalidPath
+	}
+
+	if err := o.removeWhiteout(path); err != nil {
+		return nil, err
+	}
+
+	return o.upper.Put(src, path)
+}
+
+// PutWithOptions writes the file to the upper layer with the given options, removing any
+// whiteout recorded for it.
+func (o *OverlayFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	if _, ok := whiteoutOf(path); ok {
+		return nil, ErrInvalidPath
+	}
+
+	if err := o.removeWhiteout(path); err != nil {
+		return nil, err
+	}
+
+	return PutWithOptions(o.upper, src, path, opts)
+}
+
+// Get returns the file from the first layer holding it, unless it has been whited out.
+// Files from a lower layer are wrapped so that writing to them puts to the upper layer.
+func (o *OverlayFileSystem) Get(path string) (File, error) {
+	whiteout, err := o.whitedOut(path)
+	if err != nil {
+		return nil, err
+	}
+	if whiteout {
+		return nil, notExist("get", path)
+	}
+
+	for i, layer := range o.layers() {
+		file, err := layer.Get(path)
+		if err == nil {
+			if i == 0 {
+				return file, nil
+			}
+			return &OverlayFile{file, path, o}, nil
+		}
+		if !IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, notExist("get", path)
+}
+
+// Delete removes the file from the upper layer and records a whiteout if a lower layer
+// still holds it.
+func (o *OverlayFileSystem) Delete(path string) error {
+	whiteout, err := o.whitedOut(path)
+	if err != nil {
+		return err
+	}
+	if whiteout {
+		return notExist("delete", path)
+	}
+
+	found := true
+	if err := Delete(o.upper, path); IsNotExist(err) {
+		found = false
+	} else if err != nil {
+		return err
+	}
+
+	for _, lower := range o.lowers {
+		_, err := StatPath(lower, path)
+		if IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		file, err := o.upper.Put(bytes.NewReader(nil), whiteoutPath(path))
+		if err != nil {
+			return err
+		}
+
+		return file.Close()
+	}
+
+	if !found {
+		return notExist("delete", path)
+	}
+
+	return nil
+}
+
+// List merges the paths starting with prefix in every layer, leaving out whited out files.
+func (o *OverlayFileSystem) List(prefix string) ([]string, error) {
+	// whiteouts are named after the base name of the file they hide, so a prefix ending part
+	// way through a name would not match them and the whole directory is listed instead.
+	dir, _ := path.Split(prefix)
+	upper, err := List(o.upper, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	hidden := make(map[string]bool)
+	for _, p := range upper {
+		if original, ok := whiteoutOf(p); ok {
+			hidden[original] = true
+			continue
+		}
+		if strings.HasPrefix(p, prefix) {
+			seen[p] = true
+		}
+	}
+
+	for _, lower := range o.lowers {
+		paths, err := List(lower, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			if !hidden[p] {
+				seen[p] = true
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// layers returns every layer from the top down.
+func (o *OverlayFileSystem) layers() []FileSystem {
+	return append([]FileSystem{o.upper}, o.lowers...)
+}
+
+// whitedOut reports whether the upper layer holds a whiteout for path.
+func (o *OverlayFileSystem) whitedOut(path string) (bool, error) {
+	_, err := StatPath(o.upper, whiteoutPath(path))
+	if IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// removeWhiteout deletes the whiteout for path if there is one.
+func (o *OverlayFileSystem) removeWhiteout(path string) error {
+	whiteout, err := o.whitedOut(path)
+	if err != nil || !whiteout {
+		return err
+	}
+
+	return Delete(o.upper, whiteoutPath(path))
+}
+
+// whiteoutPath returns the path of the whiteout marker for p.
+func whiteoutPath(p string) string {
+	dir, base := path.Split(p)
+	return dir + whiteoutPrefix + base
+}
+
+// whiteoutOf returns the path a whiteout marker hides, ok is false if p is not a whiteout.
+func whiteoutOf(p string) (string, bool) {
+	dir, base := path.Split(p)
+	if !strings.HasPrefix(base, whiteoutPrefix) {
+		return "", false
+	}
+
+	return dir + strings.TrimPrefix(base, whiteoutPrefix), true
+}
+
+// OverlayFile is a File read from a lower layer of an OverlayFileSystem, writing to it puts
+// the contents to the upper layer so the lower layer is never changed.
+type OverlayFile struct {
+	file File
+	path string
+	fs   *OverlayFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *OverlayFile) unwrap() File {
+	return f.file
+}
+
+// Read reads from the file in the lower layer.
+func (f *OverlayFile) Read(p []byte) (int, error) {
+	return f.file.Read(p)
+}
+
+// Seek seeks the file in the lower layer.
+func (f *OverlayFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Write replaces the contents of the file by putting p to the upper layer.
+func (f *OverlayFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
+
+// Close closes the file in the lower layer.
+func (f *OverlayFile) Close() error {
+	return f.file.Close()
+}
+
+// Stat returns the info of the file in the lower layer.
+func (f *OverlayFile) Stat() (os.FileInfo, error) {
+	return f.file.Stat()
+}
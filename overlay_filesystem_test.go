@@ -0,0 +1,176 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpOverlayFileSystem() (*OverlayFileSystem, *MemoryFileSystem, *MemoryFileSystem) {
+	upper := NewMemoryFileSystem()
+	lower := NewMemoryFileSystem()
+	lower.Put(bytes.NewReader([]byte("base")), "dir/base.txt")
+	lower.Put(bytes.NewReader([]byte("shared lower")), "dir/shared.txt")
+	upper.Put(bytes.NewReader([]byte("shared upper")), "dir/shared.txt")
+
+	return NewOverlayFileSystem(upper, lower), upper, lower
+}
+
+func TestOverlayFileSystemGetPrefersUpperLayer(t *testing.T) {
+	fs, _, _ := setUpOverlayFileSystem()
+
+	file, err := fs.Get("dir/shared.txt")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "shared upper", string(b))
+
+	file, err = fs.Get("dir/base.txt")
+	assert.Nil(t, err)
+	b, _ = ioutil.ReadAll(file)
+	assert.Equal(t, "base", string(b))
+
+	_, err = fs.Get("dir/missing.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOverlayFileSystemPutWritesUpperLayerOnly(t *testing.T) {
+	fs, upper, lower := setUpOverlayFileSystem()
+
+	_, err := fs.Put(bytes.NewReader([]byte("changed")), "dir/base.txt")
+	assert.Nil(t, err)
+
+	file, _ := upper.Get("dir/base.txt")
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "changed", string(b))
+
+	file, _ = lower.Get("dir/base.txt")
+	b, _ = ioutil.ReadAll(file)
+	assert.Equal(t, "base", string(b))
+}
+
+func TestOverlayFileSystemDeleteWhitesOutLowerFile(t *testing.T) {
+	fs, upper, lower := setUpOverlayFileSystem()
+
+	assert.Nil(t, fs.Delete("dir/base.txt"))
+
+	_, err := fs.Get("dir/base.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = upper.Get("dir/.wh.base.txt")
+	assert.Nil(t, err)
+
+	_, err = lower.Get("dir/base.txt")
+	assert.Nil(t, err)
+
+	err = fs.Delete("dir/base.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	paths, _ := fs.List("dir/")
+	assert.Equal(t, []string{"dir/shared.txt"}, paths)
+
+	fs.Put(bytes.NewReader([]byte("again")), "dir/base.txt")
+
+	file, err := fs.Get("dir/base.txt")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "again", string(b))
+
+	_, err = upper.Get("dir/.wh.base.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOverlayFileSystemDeleteUpperOnlyFileLeavesNoWhiteout(t *testing.T) {
+	fs, upper, _ := setUpOverlayFileSystem()
+	fs.Put(bytes.NewReader([]byte("new")), "dir/new.txt")
+
+	assert.Nil(t, fs.Delete("dir/new.txt"))
+
+	_, err := upper.Get("dir/.wh.new.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	err = fs.Delete("dir/missing.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOverlayFileSystemDeleteStatsLowerLayers(t *testing.T) {
+	upper := NewMemoryFileSystem()
+	lower := &getCountingFileSystem{MemoryFileSystem: NewMemoryFileSystem()}
+	lower.Put(bytes.NewReader([]byte("base")), "dir/base.txt")
+	fs := NewOverlayFileSystem(upper, lower)
+
+	assert.Nil(t, fs.Delete("dir/base.txt"))
+	assert.Equal(t, 0, lower.gets)
+
+	_, err := fs.Get("dir/base.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOverlayFileSystemRejectsWhiteoutNames(t *testing.T) {
+	fs, upper, _ := setUpOverlayFileSystem()
+
+	_, err := fs.Put(bytes.NewReader([]byte("hide")), "dir/.wh.base.txt")
+	assert.Equal(t, ErrInvalidPath, err)
+
+	_, err = fs.PutWithOptions(bytes.NewReader([]byte("hide")), "dir/.wh.base.txt", PutOptions{})
+	assert.Equal(t, ErrInvalidPath, err)
+
+	_, err = upper.Get("dir/.wh.base.txt")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = fs.Get("dir/base.txt")
+	assert.Nil(t, err)
+}
+
+// getCountingFileSystem counts the files read through Get.
+type getCountingFileSystem struct {
+	*MemoryFileSystem
+	gets int
+}
+
+func (fs *getCountingFileSystem) Get(path string) (File, error) {
+	fs.gets++
+	return fs.MemoryFileSystem.Get(path)
+}
+
+func TestOverlayFileSystemListMergesLayers(t *testing.T) {
+	fs, _, _ := setUpOverlayFileSystem()
+	fs.Put(bytes.NewReader([]byte("new")), "dir/new.txt")
+	fs.Put(bytes.NewReader([]byte("other")), "other/file.txt")
+
+	paths, err := fs.List("dir/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir/base.txt", "dir/new.txt", "dir/shared.txt"}, paths)
+}
+
+func TestOverlayFileSystemWriteToLowerFilePutsUpperLayer(t *testing.T) {
+	fs, upper, lower := setUpOverlayFileSystem()
+
+	file, err := fs.Get("dir/base.txt")
+	assert.Nil(t, err)
+
+	_, err = file.Write([]byte("changed"))
+	assert.Nil(t, err)
+
+	file, _ = upper.Get("dir/base.txt")
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "changed", string(b))
+
+	file, _ = lower.Get("dir/base.txt")
+	b, _ = ioutil.ReadAll(file)
+	assert.Equal(t, "base", string(b))
+}
+
+func TestOverlayFileSystemListHidesWhiteoutsForPartialNames(t *testing.T) {
+	fs, _, lower := setUpOverlayFileSystem()
+	lower.Put(bytes.NewReader([]byte("readme")), "docs/readme.md")
+	lower.Put(bytes.NewReader([]byte("reader")), "docs/reader.md")
+
+	assert.Nil(t, fs.Delete("docs/readme.md"))
+
+	paths, err := fs.List("docs/read")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docs/reader.md"}, paths)
+}
@@ -0,0 +1,300 @@
+package gofile
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrInvalidPath is returned when nothing is left of a path once it has been cleaned.
+	ErrInvalidPath = errors.New("gofile: invalid path")
+
+	// ErrPathTooLong is returned when a path is longer than the maximum length of a PathPolicy
+	// even with its file name truncated.
+	ErrPathTooLong = errors.New("gofile: path is longer than the maximum length")
+)
+
+// WindowsReservedNames are the device names windows does not allow as a file name, with or without an extension.
+var WindowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// s3AvoidChars are the characters s3 advises against using in keys.
+const s3AvoidChars = "\\{}^%`[]\"<>~#|"
+
+// transliterations maps letters which do not decompose to an ascii letter and an accent.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH", 'ı': "i",
+}
+
+// PathPolicy describes how the paths given to a file system are cleaned into keys, the same
+// rules are applied to every operation so that a file can be read back with the path it was
+// written with. A path with a ".." part fails with ErrPathEscape and an empty path with
+// ErrInvalidPath under every policy.
+//
+// The zero value passes paths through unchanged. Rewriting is opt in: once any option is
+// set paths are also normalised to unicode NFC, backslashes are treated as separators and
+// empty and "." parts are dropped, with a leading "/" kept.
+type PathPolicy struct {
+	// Sanitize removes control and invisible formatting characters and replaces whitespace
+	// and the characters s3 advises against with "-".
+	Sanitize bool
+
+	// FoldCompatibility normalises to NFKC instead of NFC, folding lookalikes such as
+	// fullwidth letters and ligatures to their plain form.
+	FoldCompatibility bool
+
+	// Lowercase lower cases every path.
+	Lowercase bool
+
+	// ASCII transliterates every part of a path to an ascii slug, accents are dropped and any
+	// character other than a letter, digit, ".", "_" or "-" is replaced with "-".
+	ASCII bool
+
+	// MaxLength is the longest path in bytes, the file name of a longer path is truncated
+	// keeping its extension. Zero is unlimited, s3 allows keys of up to 1024 bytes.
+	MaxLength int
+
+	// ReservedNames lists names which cannot be used for a part of a path, compared case
+	// insensitively without an extension, such as WindowsReservedNames. Reserved parts are
+	// prefixed with "_".
+	ReservedNames []string
+}
+
+// Clean returns the key for path under the policy.
+func (p PathPolicy) Clean(path string) (string, error) {
+	return p.clean(path, false)
+}
+
+// PathCleaner is implemented by file systems which clean the paths they are given with a
+// PathPolicy, so that wrappers can find the key a path is stored at.
+type PathCleaner interface {
+	CleanPath(path string) (string, error)
+}
+
+// CleanPath returns the key fs stores path at, path is returned unchanged if fs does not
+// implement PathCleaner.
+func CleanPath(fs FileSystem, path string) (string, error) {
+	if c, ok := fs.(PathCleaner); ok {
+		return c.CleanPath(path)
+	}
+
+	return path, nil
+}
+
+// cleanPrefix returns the prefix for listing keys under the policy, keeping a trailing "/"
+// and allowing an empty prefix.
+func (p PathPolicy) cleanPrefix(prefix string) (string, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return "", nil
+	}
+
+	return p.clean(prefix, true)
+}
+
+// rewrites reports whether the policy changes paths rather than passing them through.
+func (p PathPolicy) rewrites() bool {
+	return p.Sanitize || p.FoldCompatibility || p.Lowercase || p.ASCII || p.MaxLength > 0 || len(p.ReservedNames) > 0
+}
+
+// clean cleans a path, a prefix keeps its trailing "/" and is not checked against the
+// reserved names or maximum length.
+func (p PathPolicy) clean(path string, prefix bool) (string, error) {
+	if !p.rewrites() {
+		return passPath(path)
+	}
+
+	original := path
+
+	if p.FoldCompatibility {
+		path = norm.NFKC.String(path)
+	} else {
+		path = norm.NFC.String(path)
+	}
+
+	if p.Sanitize {
+		path = strings.TrimSpace(path)
+	}
+	path = strings.Replace(path, "\\", "/", -1)
+	abs := strings.HasPrefix(path, "/")
+	dir := strings.HasSuffix(path, "/")
+
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		part = p.cleanPart(part)
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", &os.PathError{Op: "clean", Path: original, Err: ErrPathEscape}
+		}
+
+		if !prefix && p.reserved(part) {
+			part = "_" + part
+		}
+
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "", &os.PathError{Op: "clean", Path: original, Err: ErrInvalidPath}
+	}
+
+	cleaned := strings.Join(parts, "/")
+	if abs {
+		cleaned = "/" + cleaned
+	}
+
+	if prefix {
+		if dir {
+			cleaned += "/"
+		}
+
+		return cleaned, nil
+	}
+
+	if p.MaxLength > 0 && len(cleaned) > p.MaxLength {
+		truncated, ok := truncatePath(cleaned, p.MaxLength)
+		if !ok {
+			return "", &os.PathError{Op: "clean", Path: original, Err: ErrPathTooLong}
+		}
+		cleaned = truncated
+	}
+
+	return cleaned, nil
+}
+
+// cleanPart cleans a single part of a path.
+func (p PathPolicy) cleanPart(part string) string {
+	if p.ASCII {
+		part = norm.NFKD.String(part)
+	}
+
+	if p.Sanitize {
+		part = strings.TrimSpace(part)
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range part {
+		switch {
+		case p.Sanitize && unicode.IsSpace(r):
+			space = true
+			continue
+		case p.Sanitize && (unicode.IsControl(r) || unicode.Is(unicode.Cf, r)):
+			continue
+		case p.ASCII && unicode.Is(unicode.Mn, r):
+			continue
+		}
+
+		if space {
+			b.WriteByte('-')
+			space = false
+		}
+
+		switch {
+		case p.Sanitize && strings.ContainsRune(s3AvoidChars, r):
+			b.WriteByte('-')
+		case p.ASCII && transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		case p.ASCII && !isSlugRune(r):
+			b.WriteByte('-')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	cleaned := b.String()
+	if p.ASCII {
+		for strings.Contains(cleaned, "--") {
+			cleaned = strings.Replace(cleaned, "--", "-", -1)
+		}
+		cleaned = strings.Trim(cleaned, "-")
+	}
+
+	if p.Lowercase {
+		cleaned = strings.ToLower(cleaned)
+	}
+
+	return cleaned
+}
+
+// passPath returns path unchanged, failing if it is empty or has a ".." part.
+func passPath(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", &os.PathError{Op: "clean", Path: path, Err: ErrInvalidPath}
+	}
+
+	for _, part := range strings.FieldsFunc(path, isSeparator) {
+		if part == ".." {
+			return "", &os.PathError{Op: "clean", Path: path, Err: ErrPathEscape}
+		}
+	}
+
+	return path, nil
+}
+
+// isSeparator reports whether r separates the parts of a path, backslashes are treated as
+// separators so that paths written on windows cannot escape.
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// reserved reports whether a part of a path is one of the reserved names, ignoring its extension.
+func (p PathPolicy) reserved(part string) bool {
+	name := part
+	if i := strings.IndexByte(part, '.'); i >= 0 {
+		name = part[:i]
+	}
+
+	for _, reserved := range p.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSlugRune reports whether r may appear in an ascii slug.
+func isSlugRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-')
+}
+
+// truncatePath shortens the file name of path so that the path fits in max bytes, keeping
+// the extension and whole runes. It fails if the directories alone do not leave room for a name.
+func truncatePath(path string, max int) (string, bool) {
+	dir, name := "", path
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+
+	ext := ""
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		ext = name[i:]
+	}
+	base := name[:len(name)-len(ext)]
+
+	room := max - len(dir) - len(ext)
+	if room <= 0 {
+		return "", false
+	}
+
+	for len(base) > room {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	if base == "" {
+		return "", false
+	}
+
+	return dir + base + ext, true
+}
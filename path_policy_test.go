@@ -0,0 +1,121 @@
+package gofile
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPolicyPassesPathsThroughByDefault(t *testing.T) {
+	for _, input := range []string{"My Docs/File.TXT", "dir//sub/./file.txt", "dir\\file.txt", "curly{brace}.txt"} {
+		actual, err := PathPolicy{}.Clean(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, input, actual, input)
+	}
+}
+
+func TestPathPolicyCleansPaths(t *testing.T) {
+	paths := map[string]string{
+		"my funky path   d":     "my-funky-path-d",
+		"  my  f  path.txt ":    "my-f-path.txt",
+		"dir\\sub\\file.txt":    "dir/sub/file.txt",
+		"dir//sub/./file.txt":   "dir/sub/file.txt",
+		"/abs/file.txt":         "/abs/file.txt",
+		"bad\x00\x1fname.txt":   "badname.txt",
+		"zero\u200bwidth.txt":   "zerowidth.txt",
+		"curly{brace}#hash.txt": "curly-brace--hash.txt",
+		"cafe\u0301/menu.pdf":   "caf\u00e9/menu.pdf",
+		"Mixed/Case.TXT":        "Mixed/Case.TXT",
+	}
+
+	for input, expected := range paths {
+		actual, err := PathPolicy{Sanitize: true}.Clean(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestPathPolicyRejectsEscapingAndEmptyPaths(t *testing.T) {
+	_, err := PathPolicy{}.Clean("dir/../../etc/passwd")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	_, err = PathPolicy{}.Clean("dir\\..\\secret")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	_, err = PathPolicy{Sanitize: true}.Clean("dir/../../etc/passwd")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	_, err = PathPolicy{}.Clean(" ")
+	assert.Equal(t, ErrInvalidPath, err.(*os.PathError).Err)
+
+	_, err = PathPolicy{Sanitize: true}.Clean(" / ./ ")
+	assert.Equal(t, ErrInvalidPath, err.(*os.PathError).Err)
+}
+
+func TestPathPolicyOptions(t *testing.T) {
+	policy := PathPolicy{
+		FoldCompatibility: true,
+		Lowercase:         true,
+		ASCII:             true,
+		ReservedNames:     WindowsReservedNames,
+	}
+
+	paths := map[string]string{
+		"Cr\u00e8me Br\u00fbl\u00e9e/Recipe.PDF": "creme-brulee/recipe.pdf",
+		"\uff21\uff22\uff23.txt":                 "abc.txt",
+		"stra\u00dfe/\ufb01le.txt":               "strasse/file.txt",
+		"docs/CON.txt":                           "docs/_con.txt",
+		"docs/con.tar.gz":                        "docs/_con.tar.gz",
+		"docs/console.txt":                       "docs/console.txt",
+		"what?! (final) v2.doc":                  "what-final-v2.doc",
+	}
+
+	for input, expected := range paths {
+		actual, err := policy.Clean(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestPathPolicyTruncatesFileNameToMaxLength(t *testing.T) {
+	policy := PathPolicy{MaxLength: 20}
+
+	actual, err := policy.Clean("dir/" + strings.Repeat("a", 30) + ".txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "dir/aaaaaaaaaaaa.txt", actual)
+
+	actual, err = policy.Clean("dir/" + strings.Repeat("\u00e9", 10) + ".txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "dir/"+strings.Repeat("\u00e9", 6)+".txt", actual)
+
+	_, err = policy.Clean(strings.Repeat("d", 20) + "/file.txt")
+	assert.Equal(t, ErrPathTooLong, err.(*os.PathError).Err)
+}
+
+func TestPathPolicyIsAppliedToEveryOperation(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.SetPathPolicy(PathPolicy{Sanitize: true, Lowercase: true})
+
+	_, err := fs.Put(bytes.NewReader([]byte("hello")), "Docs/My File.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "hello", readAll(t, fs, "docs//my file.txt"))
+
+	info, err := fs.Stat("DOCS/My   File.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), info.Size())
+
+	paths, err := fs.List("Docs/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docs/my-file.txt"}, paths)
+
+	_, err = fs.Get("docs/../../secret")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	assert.Nil(t, fs.Delete("Docs\\My File.txt"))
+	_, err = fs.Get("docs/my-file.txt")
+	assert.True(t, IsNotExist(err))
+}
@@ -0,0 +1,390 @@
+package gofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// ErrQuotaExceeded is returned when a Put would take a prefix of a QuotaFileSystem over its quota.
+var ErrQuotaExceeded = errors.New("gofile: storage quota exceeded")
+
+// DefaultUsageFile is the path in the wrapped file system a QuotaFileSystem keeps its usage
+// at when no store is given.
+const DefaultUsageFile = "gofile-usage.json"
+
+// Quota limits the storage used under a prefix, zero values are unlimited.
+type Quota struct {
+	MaxBytes   int64
+	MaxObjects int64
+}
+
+// Usage is the storage used under a prefix.
+type Usage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+// UsageStore persists the usage counters of a QuotaFileSystem.
+type UsageStore interface {
+	Load() (map[string]Usage, error)
+	Save(usage map[string]Usage) error
+}
+
+// FileUsageStore is a UsageStore which keeps the usage as JSON in a file.
+type FileUsageStore struct {
+	fs   FileSystem
+	path string
+}
+
+// NewFileUsageStore is a construct function which keeps the usage at path in fs.
+func NewFileUsageStore(fs FileSystem, path string) *FileUsageStore {
+	return &FileUsageStore{
+		fs,
+		path,
+	}
+}
+
+// Load reads the usage from the file, a missing file holds no usage.
+func (s *FileUsageStore) Load() (map[string]Usage, error) {
+	usage := make(map[string]Usage)
+
+	file, err := s.fs.Get(s.path)
+	if IsNotExist(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// Save writes the usage to the file.
+func (s *FileUsageStore) Save(usage map[string]Usage) error {
+	b, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.fs.Put(bytes.NewReader(b), s.path)
+	return err
+}
+
+// QuotaFileSystem holds the files under a prefix to a quota of bytes and objects. The usage
+// of every prefix with a quota is counted as files are put and deleted, overwriting a file
+// only counts the change in its size, and is persisted to a UsageStore. A file under
+// several prefixes with quotas counts towards each of them. Prefixes are matched against
+// the key the wrapped file system stores a file at, see CleanPath, so they should be given
+// as cleaned by its PathPolicy, e.g. lower cased under Lowercase.
+//
+// Writes are made one at a time so that the usage stays accurate. When the usage is kept
+// in a file of the wrapped file system that file is hidden from Get, List and Recalculate,
+// and putting or deleting it fails with ErrInvalidPath, so it cannot be reset through the quota.
+type QuotaFileSystem struct {
+	fs       FileSystem
+	store    UsageStore
+	usageKey string
+	mu       sync.Mutex
+	quotas   map[string]Quota
+	usage    map[string]Usage
+}
+
+// NewQuotaFileSystem is a construct function which enforces quotas on fs, keeping the usage
+// in store. A nil store keeps the usage at DefaultUsageFile in fs, next to the files it counts.
+func NewQuotaFileSystem(fs FileSystem, store UsageStore) *QuotaFileSystem {
+	if store == nil {
+		store = NewFileUsageStore(fs, DefaultUsageFile)
+	}
+
+	var usageKey string
+	if s, ok := store.(*FileUsageStore); ok && s.fs == fs {
+		usageKey, _ = CleanPath(fs, s.path)
+	}
+
+	return &QuotaFileSystem{
+		fs:       fs,
+		store:    store,
+		usageKey: usageKey,
+		quotas:   make(map[string]Quota),
+	}
+}
+
+// SetQuota sets the quota for files under prefix, an empty prefix covers every file.
+func (q *QuotaFileSystem) SetQuota(prefix string, quota Quota) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.quotas[prefix] = quota
+}
+
+// Usage returns the storage used under a prefix with a quota.
+func (q *QuotaFileSystem) Usage(prefix string) (Usage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.load(); err != nil {
+		return Usage{}, err
+	}
+
+	return q.usage[prefix], nil
+}
+
+// Recalculate counts the usage under prefix from the files in the wrapped file system,
+// for example when a quota is set on files which already exist.
+func (q *QuotaFileSystem) Recalculate(prefix string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.load(); err != nil {
+		return err
+	}
+
+	paths, err := List(q.fs, prefix)
+	if err != nil {
+		return err
+	}
+
+	var usage Usage
+	for _, p := range q.visible(paths) {
+		info, err := StatPath(q.fs, p)
+		if err != nil {
+			return err
+		}
+
+		usage.Bytes += info.Size()
+		usage.Objects++
+	}
+
+	q.usage[prefix] = usage
+	return q.store.Save(q.usage)
+}
+
+// Put writes the file unless it would take a prefix over its quota.
+func (q *QuotaFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return q.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file with the given options unless it would take a prefix over its quota.
+func (q *QuotaFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	key, err := CleanPath(q.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	if q.reserved(key) {
+		return nil, ErrInvalidPath
+	}
+
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	size := end - start
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	old, exists, err := q.existing(path)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := Usage{Bytes: size - old}
+	if !exists {
+		delta.Objects = 1
+	}
+
+	for prefix, quota := range q.quotas {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		usage := q.usage[prefix]
+		if (quota.MaxBytes > 0 && delta.Bytes > 0 && usage.Bytes+delta.Bytes > quota.MaxBytes) ||
+			(quota.MaxObjects > 0 && delta.Objects > 0 && usage.Objects+delta.Objects > quota.MaxObjects) {
+			return nil, ErrQuotaExceeded
+		}
+	}
+
+	file, err := PutWithOptions(q.fs, src, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.apply(key, delta); err != nil {
+		return nil, err
+	}
+
+	return &QuotaFile{file, path, q}, nil
+}
+
+// Get returns the file from the wrapped file system.
+func (q *QuotaFileSystem) Get(path string) (File, error) {
+	if key, err := CleanPath(q.fs, path); err == nil && q.reserved(key) {
+		return nil, notExist("get", path)
+	}
+
+	file, err := q.fs.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaFile{file, path, q}, nil
+}
+
+// Delete removes the file and releases the storage it used.
+func (q *QuotaFileSystem) Delete(path string) error {
+	key, err := CleanPath(q.fs, path)
+	if err != nil {
+		return err
+	}
+	if q.reserved(key) {
+		return ErrInvalidPath
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.load(); err != nil {
+		return err
+	}
+
+	old, exists, err := q.existing(path)
+	if err != nil {
+		return err
+	}
+
+	if err := Delete(q.fs, path); err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return q.apply(key, Usage{Bytes: -old, Objects: -1})
+}
+
+// List lists the files of the wrapped file system starting with prefix.
+func (q *QuotaFileSystem) List(prefix string) ([]string, error) {
+	paths, err := List(q.fs, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.visible(paths), nil
+}
+
+// CleanPath returns the key the wrapped file system stores path at.
+func (q *QuotaFileSystem) CleanPath(path string) (string, error) {
+	return CleanPath(q.fs, path)
+}
+
+// reserved reports whether key is the usage file kept in the wrapped file system.
+func (q *QuotaFileSystem) reserved(key string) bool {
+	return q.usageKey != "" && key == q.usageKey
+}
+
+// visible returns paths without the usage file.
+func (q *QuotaFileSystem) visible(paths []string) []string {
+	if q.usageKey == "" {
+		return paths
+	}
+
+	visible := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p != q.usageKey {
+			visible = append(visible, p)
+		}
+	}
+
+	return visible
+}
+
+// existing returns the size of the file already at path and whether there is one.
+func (q *QuotaFileSystem) existing(path string) (int64, bool, error) {
+	info, err := StatPath(q.fs, path)
+	if IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return info.Size(), true, nil
+}
+
+// apply adds delta to the usage of every prefix with a quota covering key and saves the
+// usage. The caller must hold the lock.
+func (q *QuotaFileSystem) apply(key string, delta Usage) error {
+	for prefix := range q.quotas {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		usage := q.usage[prefix]
+		usage.Bytes += delta.Bytes
+		usage.Objects += delta.Objects
+		q.usage[prefix] = usage
+	}
+
+	return q.store.Save(q.usage)
+}
+
+// load reads the usage from the store the first time it is needed, the caller must hold the lock.
+func (q *QuotaFileSystem) load() error {
+	if q.usage != nil {
+		return nil
+	}
+
+	usage, err := q.store.Load()
+	if err != nil {
+		return err
+	}
+
+	q.usage = usage
+	return nil
+}
+
+// QuotaFile is a File held by a QuotaFileSystem.
+type QuotaFile struct {
+	File
+	path string
+	fs   *QuotaFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *QuotaFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the quota file system.
+func (f *QuotaFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
@@ -0,0 +1,143 @@
+package gofile
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpQuotaFileSystem() (*QuotaFileSystem, *MemoryFileSystem, *FileUsageStore) {
+	mem := NewMemoryFileSystem()
+	store := NewFileUsageStore(NewMemoryFileSystem(), "usage.json")
+
+	fs := NewQuotaFileSystem(mem, store)
+	fs.SetQuota("tenant-1/", Quota{MaxBytes: 10, MaxObjects: 2})
+
+	return fs, mem, store
+}
+
+func TestQuotaFileSystemRejectsPutsOverQuota(t *testing.T) {
+	fs, mem, _ := setUpQuotaFileSystem()
+
+	_, err := fs.Put(bytes.NewReader([]byte("123456")), "tenant-1/a.txt")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("123456")), "tenant-1/b.txt")
+	assert.Equal(t, ErrQuotaExceeded, err)
+
+	_, err = mem.Get("tenant-1/b.txt")
+	assert.True(t, IsNotExist(err))
+
+	_, err = fs.Put(bytes.NewReader([]byte("1234")), "tenant-1/b.txt")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("")), "tenant-1/c.txt")
+	assert.Equal(t, ErrQuotaExceeded, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("123456")), "tenant-2/a.txt")
+	assert.Nil(t, err)
+
+	usage, _ := fs.Usage("tenant-1/")
+	assert.Equal(t, Usage{Bytes: 10, Objects: 2}, usage)
+}
+
+func TestQuotaFileSystemAccountsForOverwritesAndDeletes(t *testing.T) {
+	fs, _, store := setUpQuotaFileSystem()
+
+	fs.Put(bytes.NewReader([]byte("123456")), "tenant-1/a.txt")
+	_, err := fs.Put(bytes.NewReader([]byte("1234567890")), "tenant-1/a.txt")
+	assert.Nil(t, err)
+
+	usage, _ := fs.Usage("tenant-1/")
+	assert.Equal(t, Usage{Bytes: 10, Objects: 1}, usage)
+
+	assert.Nil(t, fs.Delete("tenant-1/a.txt"))
+
+	usage, _ = fs.Usage("tenant-1/")
+	assert.Equal(t, Usage{}, usage)
+
+	fs.Put(bytes.NewReader([]byte("123")), "tenant-1/b.txt")
+
+	saved, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Usage{"tenant-1/": {Bytes: 3, Objects: 1}}, saved)
+}
+
+func TestQuotaFileSystemLoadsUsageFromStore(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	store := NewFileUsageStore(NewMemoryFileSystem(), "usage.json")
+	store.Save(map[string]Usage{"tenant-1/": {Bytes: 8, Objects: 1}})
+
+	fs := NewQuotaFileSystem(mem, store)
+	fs.SetQuota("tenant-1/", Quota{MaxBytes: 10})
+
+	_, err := fs.Put(bytes.NewReader([]byte("123")), "tenant-1/a.txt")
+	assert.Equal(t, ErrQuotaExceeded, err)
+}
+
+func TestQuotaFileSystemRecalculatesUsage(t *testing.T) {
+	fs, mem, _ := setUpQuotaFileSystem()
+	mem.Put(bytes.NewReader([]byte("1234")), "tenant-1/a.txt")
+	mem.Put(bytes.NewReader([]byte("12")), "tenant-1/b.txt")
+
+	assert.Nil(t, fs.Recalculate("tenant-1/"))
+
+	usage, _ := fs.Usage("tenant-1/")
+	assert.Equal(t, Usage{Bytes: 6, Objects: 2}, usage)
+}
+
+func TestQuotaFileSystemMatchesPrefixesAgainstCleanedKeys(t *testing.T) {
+	fs, mem, _ := setUpQuotaFileSystem()
+	mem.SetPathPolicy(PathPolicy{Lowercase: true})
+
+	_, err := fs.Put(bytes.NewReader([]byte("123456")), "Tenant-1/a.txt")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("123456")), "TENANT-1/b.txt")
+	assert.Equal(t, ErrQuotaExceeded, err)
+
+	assert.Nil(t, fs.Delete("TENANT-1/A.txt"))
+
+	usage, _ := fs.Usage("tenant-1/")
+	assert.Equal(t, Usage{}, usage)
+}
+
+func TestQuotaFileSystemKeepsUsageInWrappedFileSystemByDefault(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewQuotaFileSystem(mem, nil)
+	fs.SetQuota("tenant-1/", Quota{MaxObjects: 1})
+
+	_, err := fs.Put(bytes.NewReader([]byte("123456")), "tenant-1/a.txt")
+	assert.Nil(t, err)
+
+	usage, err := NewFileUsageStore(mem, DefaultUsageFile).Load()
+	assert.Nil(t, err)
+	assert.Equal(t, Usage{Bytes: 6, Objects: 1}, usage["tenant-1/"])
+}
+
+func TestQuotaFileSystemTenantCannotResetUsage(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewQuotaFileSystem(mem, nil)
+	fs.SetQuota("", Quota{MaxObjects: 1})
+
+	_, err := fs.Put(bytes.NewReader([]byte("123456")), "a.txt")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("{}")), DefaultUsageFile)
+	assert.Equal(t, ErrInvalidPath, err)
+	assert.Equal(t, ErrInvalidPath, fs.Delete(DefaultUsageFile))
+
+	_, err = fs.Get(DefaultUsageFile)
+	assert.True(t, IsNotExist(err))
+
+	paths, _ := fs.List("")
+	assert.Equal(t, []string{"a.txt"}, paths)
+
+	assert.Nil(t, fs.Recalculate(""))
+	usage, _ := fs.Usage("")
+	assert.Equal(t, Usage{Bytes: 6, Objects: 1}, usage)
+
+	_, err = fs.Put(bytes.NewReader([]byte("123456")), "b.txt")
+	assert.Equal(t, ErrQuotaExceeded, err)
+}
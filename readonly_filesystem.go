@@ -0,0 +1,133 @@
+package gofile
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrReadOnly is returned when a write is made to a read only file system or one of its files.
+var ErrReadOnly = errors.New("gofile: file system is read only")
+
+// ReadOnlyFileSystem allows reads from a wrapped file system and rejects every write, see ReadOnly.
+type ReadOnlyFileSystem struct {
+	fs FileSystem
+}
+
+// ReadOnly returns a FileSystem which reads from fs but fails every Put, Delete, Move and
+// write to a returned File with ErrReadOnly, so that fs cannot be changed through it.
+func ReadOnly(fs FileSystem) FileSystem {
+	return &ReadOnlyFileSystem{fs}
+}
+
+// Put returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return nil, ErrReadOnly
+}
+
+// PutWithOptions returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	return r.Put(src, path)
+}
+
+// Delete returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) Delete(path string) error {
+	return ErrReadOnly
+}
+
+// Move returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) Move(from, to string) error {
+	return ErrReadOnly
+}
+
+// Get returns the file from the wrapped file system, writes to it fail with ErrReadOnly.
+func (r *ReadOnlyFileSystem) Get(path string) (File, error) {
+	file, err := r.fs.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadOnlyFile{file}, nil
+}
+
+// Stat returns the info of the file from the wrapped file system.
+func (r *ReadOnlyFileSystem) Stat(path string) (FileInfo, error) {
+	return StatPath(r.fs, path)
+}
+
+// List lists the files of the wrapped file system starting with prefix.
+func (r *ReadOnlyFileSystem) List(prefix string) ([]string, error) {
+	return List(r.fs, prefix)
+}
+
+// Versions lists the versions of the file if the wrapped file system is a Versioner.
+func (r *ReadOnlyFileSystem) Versions(path string) ([]Version, error) {
+	v, ok := r.fs.(Versioner)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return v.Versions(path)
+}
+
+// GetVersion returns a version of the file if the wrapped file system is a Versioner,
+// writes to it fail with ErrReadOnly.
+func (r *ReadOnlyFileSystem) GetVersion(path, id string) (File, error) {
+	v, ok := r.fs.(Versioner)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	file, err := v.GetVersion(path, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadOnlyFile{file}, nil
+}
+
+// DeleteVersion returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) DeleteVersion(path, id string) error {
+	return ErrReadOnly
+}
+
+// RestoreVersion returns ErrReadOnly.
+func (r *ReadOnlyFileSystem) RestoreVersion(path, id string) (File, error) {
+	return nil, ErrReadOnly
+}
+
+// ReadOnlyFile is a File from a ReadOnlyFileSystem, writes to it fail with ErrReadOnly.
+// The wrapped file is not exposed so that it cannot be written to directly.
+type ReadOnlyFile struct {
+	file File
+}
+
+// unwrap returns the wrapped file.
+func (f *ReadOnlyFile) unwrap() File {
+	return f.file
+}
+
+// Read reads from the wrapped file.
+func (f *ReadOnlyFile) Read(p []byte) (n int, err error) {
+	return f.file.Read(p)
+}
+
+// Seek seeks the wrapped file.
+func (f *ReadOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Write returns ErrReadOnly without writing anything.
+func (f *ReadOnlyFile) Write(p []byte) (n int, err error) {
+	return 0, ErrReadOnly
+}
+
+// Close closes the wrapped file.
+func (f *ReadOnlyFile) Close() error {
+	return f.file.Close()
+}
+
+// Stat returns the info of the wrapped file.
+func (f *ReadOnlyFile) Stat() (os.FileInfo, error) {
+	return f.file.Stat()
+}
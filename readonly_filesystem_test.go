@@ -0,0 +1,48 @@
+package gofile
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyFileSystemAllowsReads(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("contents")), "data/file.txt")
+	fs := ReadOnly(mem)
+
+	assert.Equal(t, "contents", readAll(t, fs, "data/file.txt"))
+
+	info, err := StatPath(fs, "data/file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), info.Size())
+
+	paths, err := List(fs, "data/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"data/file.txt"}, paths)
+}
+
+func TestReadOnlyFileSystemRejectsWrites(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	fs := ReadOnly(mem)
+
+	_, err := fs.Put(bytes.NewReader([]byte("changed")), "file.txt")
+	assert.Equal(t, ErrReadOnly, err)
+
+	_, err = PutWithOptions(fs, bytes.NewReader([]byte("changed")), "file.txt", PutOptions{})
+	assert.Equal(t, ErrReadOnly, err)
+
+	assert.Equal(t, ErrReadOnly, Delete(fs, "file.txt"))
+	assert.Equal(t, ErrReadOnly, Move(fs, "file.txt", "moved.txt"))
+	assert.Equal(t, ErrReadOnly, fs.(Versioner).DeleteVersion("file.txt", "1"))
+
+	file, _ := fs.Get("file.txt")
+	n, err := file.Write([]byte("changed"))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, ErrReadOnly, err)
+
+	versions, _ := mem.Versions("file.txt")
+	assert.Len(t, versions, 1)
+}
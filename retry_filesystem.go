@@ -0,0 +1,247 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+// RetryPolicy configures how a RetryFileSystem retries failed operations.
+type RetryPolicy struct {
+	// MaxAttempts is the most times an operation is tried, including the first attempt, defaults to 3.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, it doubles for every retry after, defaults to 100ms.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the wait between retries, defaults to 10s.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each wait which is randomly taken off
+	// so that many clients retrying at once spread out.
+	Jitter float64
+
+	// Retryable reports whether an error is worth retrying, defaults to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// IsRetryable reports whether an error returned from a file system is transient: s3
+// throttling and server errors, timeouts and connection resets.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if e, ok := err.(interface {
+		Code() string
+	}); ok {
+		switch e.Code() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded",
+			"RequestTimeout", "RequestTimeoutException", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+
+	if e, ok := err.(interface {
+		StatusCode() int
+	}); ok && (e.StatusCode() == 429 || e.StatusCode() >= 500) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if e, ok := err.(interface {
+		OrigErr() error
+	}); ok && e.OrigErr() != nil {
+		return IsRetryable(e.OrigErr())
+	}
+
+	return false
+}
+
+// RetryFileSystem retries the operations of a wrapped file system which fail with a
+// retryable error, waiting with exponential backoff and jitter between attempts.
+type RetryFileSystem struct {
+	fs     FileSystem
+	policy RetryPolicy
+	sleep  func(d time.Duration)
+	rand   func() float64
+}
+
+// NewRetryFileSystem is a construct function which retries the operations of fs following policy,
+// zero fields of the policy are given their defaults.
+func NewRetryFileSystem(fs FileSystem, policy RetryPolicy) *RetryFileSystem {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultRetryAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultRetryBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultRetryMaxDelay
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+
+	return &RetryFileSystem{
+		fs:     fs,
+		policy: policy,
+		sleep:  time.Sleep,
+		rand:   rand.Float64,
+	}
+}
+
+// Put writes the file, rewinding src to where it started before every attempt.
+func (r *RetryFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return r.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file with the given options, rewinding src to where it
+// started before every attempt.
+func (r *RetryFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	err = r.do(func() (err error) {
+		if _, err := src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+
+		file, err = PutWithOptions(r.fs, src, path, opts)
+		if err != nil && file != nil {
+			file.Close()
+			file = nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.newFile(file, path), nil
+}
+
+// Get returns the file, retrying transient errors.
+func (r *RetryFileSystem) Get(path string) (File, error) {
+	var file File
+	err := r.do(func() (err error) {
+		file, err = r.fs.Get(path)
+		if err != nil && file != nil {
+			file.Close()
+			file = nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.newFile(file, path), nil
+}
+
+// Stat returns the info of the file, retrying transient errors.
+func (r *RetryFileSystem) Stat(path string) (FileInfo, error) {
+	var info FileInfo
+	err := r.do(func() (err error) {
+		info, err = StatPath(r.fs, path)
+		return err
+	})
+
+	return info, err
+}
+
+// Delete removes the file, retrying transient errors.
+func (r *RetryFileSystem) Delete(path string) error {
+	return r.do(func() error {
+		return Delete(r.fs, path)
+	})
+}
+
+// List lists the files starting with prefix, retrying transient errors.
+func (r *RetryFileSystem) List(prefix string) ([]string, error) {
+	var paths []string
+	err := r.do(func() (err error) {
+		paths, err = List(r.fs, prefix)
+		return err
+	})
+
+	return paths, err
+}
+
+// do calls op until it succeeds, fails with an error which is not retryable or runs out of attempts.
+func (r *RetryFileSystem) do(op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.policy.Retryable(err) {
+			return err
+		}
+
+		r.sleep(r.backoff(attempt))
+	}
+}
+
+// backoff returns the wait after the given attempt.
+func (r *RetryFileSystem) backoff(attempt int) time.Duration {
+	delay := r.policy.MaxDelay
+	if attempt < 32 {
+		if d := r.policy.BaseDelay << uint(attempt-1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	if r.policy.Jitter > 0 {
+		delay -= time.Duration(r.rand() * r.policy.Jitter * float64(delay))
+	}
+
+	return delay
+}
+
+// newFile wraps a file so that writes to it are retried.
+func (r *RetryFileSystem) newFile(file File, path string) *RetryFile {
+	return &RetryFile{
+		file,
+		path,
+		r,
+	}
+}
+
+// RetryFile is a File read from a RetryFileSystem.
+type RetryFile struct {
+	File
+	path string
+	fs   *RetryFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *RetryFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the retry file system.
+func (f *RetryFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
@@ -0,0 +1,104 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setUpRetryFileSystem(policy RetryPolicy) (*RetryFileSystem, *MockFileSystem, *[]time.Duration) {
+	wrapped := new(MockFileSystem)
+	var sleeps []time.Duration
+
+	fs := NewRetryFileSystem(wrapped, policy)
+	fs.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	fs.rand = func() float64 { return 0.5 }
+
+	return fs, wrapped, &sleeps
+}
+
+func TestRetryFileSystemRetriesPutWithBackoff(t *testing.T) {
+	fs, wrapped, sleeps := setUpRetryFileSystem(RetryPolicy{})
+	throttled := awserr.New("SlowDown", "slow down", nil)
+
+	var bodies []string
+	record := func(args mock.Arguments) {
+		b, _ := ioutil.ReadAll(args.Get(0).(io.Reader))
+		bodies = append(bodies, string(b))
+	}
+	wrapped.On("Put", mock.Anything, "file.txt").Return(nil, throttled).Run(record).Twice()
+	wrapped.On("Put", mock.Anything, "file.txt").Return(new(MemoryFile), nil).Run(record).Once()
+
+	src := bytes.NewReader([]byte("contents"))
+	_, err := fs.Put(src, "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"contents", "contents", "contents"}, bodies)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, *sleeps)
+}
+
+func TestRetryFileSystemGivesUpAfterMaxAttempts(t *testing.T) {
+	fs, wrapped, sleeps := setUpRetryFileSystem(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, Jitter: 0.5})
+	unavailable := awserr.NewRequestFailure(awserr.New("Unknown", "unavailable", nil), 503, "id")
+
+	wrapped.On("Get", "file.txt").Return(nil, unavailable).Twice()
+
+	_, err := fs.Get("file.txt")
+	assert.Equal(t, unavailable, err)
+
+	wrapped.AssertNumberOfCalls(t, "Get", 2)
+	assert.Equal(t, []time.Duration{750 * time.Millisecond}, *sleeps)
+}
+
+func TestRetryFileSystemDoesNotRetryPermanentErrors(t *testing.T) {
+	fs, wrapped, _ := setUpRetryFileSystem(RetryPolicy{})
+	wrapped.On("Get", "file.txt").Return(nil, notExist("get", "file.txt")).Once()
+
+	_, err := fs.Get("file.txt")
+	assert.True(t, IsNotExist(err))
+	wrapped.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestRetryFileSystemBackoffIsCapped(t *testing.T) {
+	fs, _, _ := setUpRetryFileSystem(RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+
+	assert.Equal(t, time.Second, fs.backoff(1))
+	assert.Equal(t, 4*time.Second, fs.backoff(3))
+	assert.Equal(t, 5*time.Second, fs.backoff(4))
+	assert.Equal(t, 5*time.Second, fs.backoff(100))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(awserr.New("SlowDown", "", nil)))
+	assert.True(t, IsRetryable(awserr.New("RequestError", "send request failed", syscall.ECONNRESET)))
+	assert.True(t, IsRetryable(awserr.NewRequestFailure(awserr.New("Throttled", "", nil), 429, "")))
+	assert.False(t, IsRetryable(awserr.New("AccessDenied", "", nil)))
+	assert.True(t, IsRetryable(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}))
+	assert.True(t, IsRetryable(fmt.Errorf("upload: %w", &net.DNSError{IsTimeout: true})))
+	assert.False(t, IsRetryable(errors.New("bad request")))
+	assert.False(t, IsRetryable(errors.New("invalid key: connection reset")))
+	assert.False(t, IsRetryable(nil))
+}
+
+func TestRetryFileSystemClosesFilesFromFailedAttempts(t *testing.T) {
+	fs, wrapped, _ := setUpRetryFileSystem(RetryPolicy{})
+
+	failed := new(MockFile)
+	failed.On("Close").Return(nil)
+	wrapped.On("Put", mock.Anything, "file.txt").Return(failed, awserr.New("SlowDown", "", nil)).Once()
+	wrapped.On("Put", mock.Anything, "file.txt").Return(new(MemoryFile), nil).Once()
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "file.txt")
+	assert.Nil(t, err)
+	failed.AssertNumberOfCalls(t, "Close", 1)
+}
@@ -2,9 +2,16 @@ package gofile
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -17,10 +24,14 @@ import (
 // again the s3 filesystem supports just two methods,
 // Put and Get which return File interfaces
 type S3FileSystem struct {
-	bucket string
-	config *aws.Config
-	caller S3Caller
-	time   Time
+	bucket   string
+	config   *aws.Config
+	caller   S3Caller
+	time     Time
+	options  PutOptions
+	checksum ChecksumAlgorithm
+	verify   bool
+	policy   PathPolicy
 }
 
 // NewS3FileSystem is a construct function takes both the region, bucket, and credential provider of your s3 filesystem.
@@ -29,61 +40,700 @@ type S3FileSystem struct {
 // it is recommended to use the aws.EnvProvider with the filesystem
 func NewS3FileSystem(region, bucket string, provider credentials.Provider) *S3FileSystem {
 	return &S3FileSystem{
-		bucket,
-		&aws.Config{
+		bucket: bucket,
+		config: &aws.Config{
 			Region:      aws.String(region),
 			Credentials: credentials.NewCredentials(provider),
 		},
-		new(S3Call),
-		new(OSTime),
+		caller:   new(S3Call),
+		time:     new(OSTime),
+		checksum: ChecksumMD5,
+		policy:   PathPolicy{Sanitize: true},
 	}
 }
 
+// SetChecksum sets the algorithm used to checksum objects as they are put, s3 rejects
+// uploads which do not match. The default is ChecksumMD5. When verify is true Get checks
+// objects against the checksum s3 holds for them and returns ErrChecksumMismatch if they differ.
+func (fs *S3FileSystem) SetChecksum(algo ChecksumAlgorithm, verify bool) {
+	fs.checksum = algo
+	fs.verify = verify
+}
+
+// SetDefaultPutOptions sets the options used for every object the file system writes,
+// options passed to PutWithOptions take precedence over the defaults.
+// A default SSECustomerKey is also sent when reading objects as s3 requires it to decrypt them.
+func (fs *S3FileSystem) SetDefaultPutOptions(opts PutOptions) {
+	fs.options = opts
+}
+
+// SetPathPolicy sets the policy paths are cleaned with before they are used as keys.
+func (fs *S3FileSystem) SetPathPolicy(policy PathPolicy) {
+	fs.policy = policy
+}
+
+// CleanPath returns the key path is stored at under the path policy.
+func (fs *S3FileSystem) CleanPath(path string) (string, error) {
+	return fs.policy.Clean(path)
+}
+
 // Get finds and return a File using a specific s3 key.
 func (fs *S3FileSystem) Get(path string) (File, error) {
-	svc := fs.caller.NewSvc(fs.config)
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(S3File), err
+	}
 
 	params := &s3.GetObjectInput{
 		Bucket: aws.String(fs.bucket),
 		Key:    aws.String(path),
 	}
 
+	return fs.getObject(params)
+}
+
+// getObject fetches an object from s3 and wraps the response in a File.
+func (fs *S3FileSystem) getObject(params *s3.GetObjectInput) (*S3File, error) {
+	svc := fs.caller.NewSvc(fs.config)
+
+	if fs.options.SSECustomerKey != "" {
+		params.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		params.SSECustomerKey = aws.String(fs.options.SSECustomerKey)
+	}
+
+	if fs.verify {
+		params.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+	}
+
 	resp, err := svc.GetObject(params)
 
 	if err != nil {
 		return &S3File{}, err
 	}
 
-	r, _ := ioutil.ReadAll(resp.Body)
+	r, err := ioutil.ReadAll(resp.Body)
 	defer resp.Body.Close()
-	return NewS3File(r, fs.FileUrl(path), resp.LastModified, fs), nil
+	if err != nil {
+		return &S3File{}, err
+	}
+
+	if fs.verify {
+		if err := verifyS3Checksum(r, resp); err != nil {
+			return &S3File{}, err
+		}
+	}
+
+	file := NewS3File(r, aws.StringValue(params.Key), resp.LastModified, fs)
+	file.info.etag = aws.StringValue(resp.ETag)
+	file.info.contentType = aws.StringValue(resp.ContentType)
+	file.info.contentEncoding = aws.StringValue(resp.ContentEncoding)
+	file.info.storageClass = aws.StringValue(resp.StorageClass)
+	file.info.version = aws.StringValue(resp.VersionId)
+	file.info.metadata = aws.StringValueMap(resp.Metadata)
+
+	return file, nil
 }
 
 // Put uploads a a readers contents to a specific s3 key.
 // the function is in charge of starting a session and sending the a structured request to the api
 // it returns a File interface from the response which can be used to get information about the upload
 func (fs *S3FileSystem) Put(src io.ReadSeeker, path string) (File, error) {
-	svc := fs.caller.NewSvc(fs.config)
+	return fs.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions uploads a readers contents to a specific s3 key like Put, applying the
+// encoding, metadata, encryption, storage class, acl and tagging options on top of the file system defaults.
+func (fs *S3FileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	content, err := ioutil.ReadAll(src)
+	if err != nil {
+		return new(S3File), err
+	}
 
-	path = SanitizePath(path)
-	mimeType := GetMIMETypeFromPath(path)
+	svc := fs.caller.NewSvc(fs.config)
+	opts = fs.options.merge(opts)
 
-	content, _ := ioutil.ReadAll(src)
+	mimeType := opts.ContentType
+	if mimeType == "" {
+		mimeType = contentTypeFor(path, bytes.NewReader(content))
+	}
 	params := &s3.PutObjectInput{
+		Bucket:          aws.String(fs.bucket),
+		Key:             aws.String(path),
+		Body:            bytes.NewReader(content),
+		ContentLength:   aws.Int64(int64(len(content))),
+		ContentType:     aws.String(mimeType),
+		ContentEncoding: optionalString(opts.ContentEncoding),
+		ACL:             optionalString(opts.ACL),
+		StorageClass:    optionalString(opts.StorageClass),
+		Tagging:         optionalString(opts.tagging()),
+	}
+
+	params.ServerSideEncryption, params.SSEKMSKeyId = opts.serverSideEncryption()
+	params.SSECustomerAlgorithm, params.SSECustomerKey = opts.customerKey()
+	if len(opts.Metadata) > 0 {
+		params.Metadata = aws.StringMap(opts.Metadata)
+	}
+
+	if sum := fs.checksum.Sum(content); sum != nil {
+		encoded := aws.String(base64.StdEncoding.EncodeToString(sum))
+		switch fs.checksum {
+		case ChecksumMD5:
+			params.ContentMD5 = encoded
+		case ChecksumCRC32C:
+			params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmCrc32c)
+			params.ChecksumCRC32C = encoded
+		case ChecksumSHA256:
+			params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+			params.ChecksumSHA256 = encoded
+		}
+	}
+
+	resp, err := svc.PutObject(params)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	now := fs.time.Now()
+	file := NewS3File(content, path, &now, fs)
+	file.info.contentType = mimeType
+	file.info.contentEncoding = opts.ContentEncoding
+	file.info.storageClass = opts.StorageClass
+	file.info.metadata = opts.Metadata
+	if resp != nil {
+		file.info.etag = aws.StringValue(resp.ETag)
+		file.info.version = aws.StringValue(resp.VersionId)
+	}
+
+	return file, nil
+}
+
+// multipartPartSize is the size of each part of a multipart upload, the smallest s3 allows.
+const multipartPartSize = 5 << 20
+
+// PutStream uploads the contents of a reader which cannot seek to a specific s3 key, holding
+// a single part of 5MiB in memory at a time. Contents smaller than a part are uploaded with
+// PutWithOptions, larger contents with a multipart upload which is aborted if reading src or
+// uploading a part fails. The returned file downloads the object when it is first read.
+func (fs *S3FileSystem) PutStream(src io.Reader, path string, opts PutOptions) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	part := make([]byte, multipartPartSize)
+	n, err := io.ReadFull(src, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fs.PutWithOptions(bytes.NewReader(part[:n]), path, opts)
+	}
+	if err != nil {
+		return new(S3File), err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+	opts = fs.options.merge(opts)
+
+	mimeType := opts.ContentType
+	if mimeType == "" {
+		mimeType = contentTypeFor(path, bytes.NewReader(part))
+	}
+
+	params := &s3.CreateMultipartUploadInput{
+		Bucket:          aws.String(fs.bucket),
+		Key:             aws.String(path),
+		ContentType:     aws.String(mimeType),
+		ContentEncoding: optionalString(opts.ContentEncoding),
+		ACL:             optionalString(opts.ACL),
+		StorageClass:    optionalString(opts.StorageClass),
+		Tagging:         optionalString(opts.tagging()),
+	}
+
+	params.ServerSideEncryption, params.SSEKMSKeyId = opts.serverSideEncryption()
+	params.SSECustomerAlgorithm, params.SSECustomerKey = opts.customerKey()
+	if len(opts.Metadata) > 0 {
+		params.Metadata = aws.StringMap(opts.Metadata)
+	}
+
+	switch fs.checksum {
+	case ChecksumCRC32C:
+		params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmCrc32c)
+	case ChecksumSHA256:
+		params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+	}
+
+	upload, err := svc.CreateMultipartUpload(params)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	var parts []*s3.CompletedPart
+	var size int64
+	for number := int64(1); ; number++ {
+		completed, err := fs.uploadPart(svc, path, upload.UploadId, number, part[:n], opts)
+		if err != nil {
+			fs.abortUpload(svc, path, upload.UploadId)
+			return new(S3File), err
+		}
+
+		parts = append(parts, completed)
+		size += int64(n)
+
+		n, err = io.ReadFull(src, part)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fs.abortUpload(svc, path, upload.UploadId)
+			return new(S3File), err
+		}
+	}
+
+	resp, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(fs.bucket),
+		Key:             aws.String(path),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		fs.abortUpload(svc, path, upload.UploadId)
+		return new(S3File), err
+	}
+
+	now := fs.time.Now()
+	file := NewS3File(nil, path, &now, fs)
+	file.r = &s3ObjectReader{fs: fs, key: path}
+	file.info.size = size
+	file.info.contentType = mimeType
+	file.info.contentEncoding = opts.ContentEncoding
+	file.info.storageClass = opts.StorageClass
+	file.info.metadata = opts.Metadata
+	if resp != nil {
+		file.info.etag = aws.StringValue(resp.ETag)
+		file.info.version = aws.StringValue(resp.VersionId)
+	}
+
+	return file, nil
+}
+
+// uploadPart uploads a single part of a multipart upload, checksummed with the algorithm of the file system.
+func (fs *S3FileSystem) uploadPart(svc S3Caller, path string, uploadID *string, number int64, part []byte, opts PutOptions) (*s3.CompletedPart, error) {
+	params := &s3.UploadPartInput{
 		Bucket:        aws.String(fs.bucket),
 		Key:           aws.String(path),
-		Body:          bytes.NewReader(content),
-		ContentLength: aws.Int64(int64(len(content))),
-		ContentType:   aws.String(mimeType),
+		UploadId:      uploadID,
+		PartNumber:    aws.Int64(number),
+		Body:          bytes.NewReader(part),
+		ContentLength: aws.Int64(int64(len(part))),
+	}
+	params.SSECustomerAlgorithm, params.SSECustomerKey = opts.customerKey()
+
+	completed := &s3.CompletedPart{PartNumber: aws.Int64(number)}
+	if sum := fs.checksum.Sum(part); sum != nil {
+		encoded := aws.String(base64.StdEncoding.EncodeToString(sum))
+		switch fs.checksum {
+		case ChecksumMD5:
+			params.ContentMD5 = encoded
+		case ChecksumCRC32C:
+			params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmCrc32c)
+			params.ChecksumCRC32C = encoded
+			completed.ChecksumCRC32C = encoded
+		case ChecksumSHA256:
+			params.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+			params.ChecksumSHA256 = encoded
+			completed.ChecksumSHA256 = encoded
+		}
+	}
+
+	resp, err := svc.UploadPart(params)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := svc.PutObject(params)
+	completed.ETag = resp.ETag
+	return completed, nil
+}
+
+// abortUpload aborts a multipart upload so that s3 does not keep the uploaded parts, the
+// error of the failed upload is more useful to the caller so an abort error is dropped.
+func (fs *S3FileSystem) abortUpload(svc S3Caller, path string, uploadID *string) {
+	svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(path),
+		UploadId: uploadID,
+	})
+}
+
+// s3ObjectReader reads an object which was streamed to s3, downloading it when it is first read.
+type s3ObjectReader struct {
+	fs  *S3FileSystem
+	key string
+	r   io.ReadSeeker
+}
+
+// load downloads the object the first time it is needed.
+func (o *s3ObjectReader) load() error {
+	if o.r != nil {
+		return nil
+	}
+
+	file, err := o.fs.getObject(&s3.GetObjectInput{
+		Bucket: aws.String(o.fs.bucket),
+		Key:    aws.String(o.key),
+	})
+	if err != nil {
+		return err
+	}
+
+	o.r = file.r
+	return nil
+}
+
+// Read reads from the downloaded object.
+func (o *s3ObjectReader) Read(p []byte) (int, error) {
+	if err := o.load(); err != nil {
+		return 0, err
+	}
+
+	return o.r.Read(p)
+}
+
+// Seek seeks the downloaded object.
+func (o *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	if err := o.load(); err != nil {
+		return 0, err
+	}
+
+	return o.r.Seek(offset, whence)
+}
+
+// Presign returns a url which downloads the object at the given key without credentials
+// until it expires.
+func (fs *S3FileSystem) Presign(path string, expires time.Duration) (string, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return "", err
+	}
+
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(path),
+	}
+	params.SSECustomerAlgorithm, params.SSECustomerKey = fs.options.customerKey()
+
+	return fs.caller.NewSvc(fs.config).PresignGetObject(params, expires)
+}
+
+// Stat returns the info of the object at the given key from a HEAD request, without downloading it.
+func (fs *S3FileSystem) Stat(path string) (FileInfo, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+
+	params := &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(path),
+	}
+	params.SSECustomerAlgorithm, params.SSECustomerKey = fs.options.customerKey()
+
+	resp, err := svc.HeadObject(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3FileInfo{
+		key:             path,
+		url:             fs.FileUrl(path),
+		size:            aws.Int64Value(resp.ContentLength),
+		mod:             resp.LastModified,
+		etag:            aws.StringValue(resp.ETag),
+		contentType:     aws.StringValue(resp.ContentType),
+		contentEncoding: aws.StringValue(resp.ContentEncoding),
+		storageClass:    aws.StringValue(resp.StorageClass),
+		version:         aws.StringValue(resp.VersionId),
+		metadata:        aws.StringValueMap(resp.Metadata),
+	}, nil
+}
+
+// Delete removes the object at the given key, on a versioned bucket a delete marker is
+// added and the previous versions are kept.
+func (fs *S3FileSystem) Delete(path string) error {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+
+	params := &s3.DeleteObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(path),
+	}
+
+	_, err = svc.DeleteObject(params)
+	return err
+}
+
+// List returns the keys of every object in the bucket starting with prefix.
+func (fs *S3FileSystem) List(prefix string) ([]string, error) {
+	prefix, err := fs.policy.cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+
+	params := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var keys []string
+	for {
+		resp, err := svc.ListObjectsV2(params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range resp.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+
+		params.ContinuationToken = resp.NextContinuationToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Versions lists every version of the object at the given key, including delete markers,
+// newest first. The bucket must have versioning enabled for more than one version to be returned.
+func (fs *S3FileSystem) Versions(path string) ([]Version, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+
+	params := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(path),
+	}
+
+	var versions []Version
+	for {
+		resp, err := svc.ListObjectVersions(params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range resp.Versions {
+			if aws.StringValue(v.Key) != path {
+				continue
+			}
+
+			versions = append(versions, Version{
+				Key:      path,
+				ID:       aws.StringValue(v.VersionId),
+				IsLatest: aws.BoolValue(v.IsLatest),
+				Size:     aws.Int64Value(v.Size),
+				ETag:     aws.StringValue(v.ETag),
+				ModTime:  aws.TimeValue(v.LastModified),
+			})
+		}
+
+		for _, m := range resp.DeleteMarkers {
+			if aws.StringValue(m.Key) != path {
+				continue
+			}
+
+			versions = append(versions, Version{
+				Key:          path,
+				ID:           aws.StringValue(m.VersionId),
+				IsLatest:     aws.BoolValue(m.IsLatest),
+				DeleteMarker: true,
+				ModTime:      aws.TimeValue(m.LastModified),
+			})
+		}
+
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+
+		params.KeyMarker = resp.NextKeyMarker
+		params.VersionIdMarker = resp.NextVersionIdMarker
+	}
+
+	sortVersions(versions)
+	return versions, nil
+}
+
+// GetVersion returns a File holding the contents of a specific version of the object at key.
+func (fs *S3FileSystem) GetVersion(path, id string) (File, error) {
+	path, err := fs.policy.Clean(path)
 	if err != nil {
 		return new(S3File), err
 	}
 
-	now := fs.time.Now()
-	return NewS3File(content, fs.FileUrl(path), &now, fs), nil
+	params := &s3.GetObjectInput{
+		Bucket:    aws.String(fs.bucket),
+		Key:       aws.String(path),
+		VersionId: aws.String(id),
+	}
+
+	return fs.getObject(params)
+}
+
+// DeleteVersion permanently removes a specific version of the object at key.
+func (fs *S3FileSystem) DeleteVersion(path, id string) error {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+
+	params := &s3.DeleteObjectInput{
+		Bucket:    aws.String(fs.bucket),
+		Key:       aws.String(path),
+		VersionId: aws.String(id),
+	}
+
+	_, err = svc.DeleteObject(params)
+	return err
+}
+
+// RestoreVersion makes an older version the current version of the object by copying it
+// over itself on the server side, the history of the object is left intact.
+func (fs *S3FileSystem) RestoreVersion(path, id string) (File, error) {
+	path, err := fs.policy.Clean(path)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	svc := fs.caller.NewSvc(fs.config)
+	opts := fs.options
+
+	params := &s3.CopyObjectInput{
+		Bucket:       aws.String(fs.bucket),
+		Key:          aws.String(path),
+		CopySource:   aws.String(url.PathEscape(fs.bucket+"/"+path) + "?versionId=" + url.QueryEscape(id)),
+		ACL:          optionalString(opts.ACL),
+		StorageClass: optionalString(opts.StorageClass),
+	}
+
+	params.ServerSideEncryption, params.SSEKMSKeyId = opts.serverSideEncryption()
+	params.SSECustomerAlgorithm, params.SSECustomerKey = opts.customerKey()
+	params.CopySourceSSECustomerAlgorithm, params.CopySourceSSECustomerKey = opts.customerKey()
+	if tagging := opts.tagging(); tagging != "" {
+		params.Tagging = aws.String(tagging)
+		params.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+	}
+
+	resp, err := svc.CopyObject(params)
+	if err != nil {
+		return new(S3File), err
+	}
+
+	if resp != nil && resp.VersionId != nil {
+		return fs.GetVersion(path, *resp.VersionId)
+	}
+
+	return fs.Get(path)
+}
+
+// verifyS3Checksum checks the contents of an object against the strongest checksum s3 returned
+// for it. The etag is only an md5 of the contents for objects uploaded in a single part
+// without kms or customer key encryption, so it is skipped for any other object. Objects
+// uploaded in parts have composite checksums ending in "-N", a checksum of the checksums
+// of each part rather than of the contents, which are skipped in the same way.
+func verifyS3Checksum(content []byte, resp *s3.GetObjectOutput) error {
+	var expected, actual string
+
+	switch {
+	case fullObjectChecksum(resp.ChecksumSHA256):
+		expected = *resp.ChecksumSHA256
+		actual = base64.StdEncoding.EncodeToString(ChecksumSHA256.Sum(content))
+	case fullObjectChecksum(resp.ChecksumCRC32C):
+		expected = *resp.ChecksumCRC32C
+		actual = base64.StdEncoding.EncodeToString(ChecksumCRC32C.Sum(content))
+	default:
+		etag := strings.Trim(aws.StringValue(resp.ETag), "\"")
+		if len(etag) != 32 || strings.Contains(etag, "-") ||
+			aws.StringValue(resp.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms ||
+			resp.SSECustomerAlgorithm != nil {
+			return nil
+		}
+
+		expected = etag
+		actual = hex.EncodeToString(ChecksumMD5.Sum(content))
+	}
+
+	if expected != actual {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// fullObjectChecksum reports whether s3 returned a checksum of the whole contents, rather
+// than none or a composite checksum of the parts of a multipart upload.
+func fullObjectChecksum(checksum *string) bool {
+	return checksum != nil && !strings.Contains(*checksum, "-")
+}
+
+// sseCustomerAlgorithm is the only algorithm s3 supports for customer provided keys.
+const sseCustomerAlgorithm = "AES256"
+
+// serverSideEncryption returns the ServerSideEncryption and SSEKMSKeyId parameters for
+// the options, a kms key id on its own implies aws:kms encryption.
+func (o PutOptions) serverSideEncryption() (*string, *string) {
+	sse := o.ServerSideEncryption
+	if sse == "" && o.SSEKMSKeyId != "" {
+		sse = s3.ServerSideEncryptionAwsKms
+	}
+
+	return optionalString(sse), optionalString(o.SSEKMSKeyId)
+}
+
+// customerKey returns the SSECustomerAlgorithm and SSECustomerKey parameters for the options,
+// the sdk computes the key md5 header itself.
+func (o PutOptions) customerKey() (*string, *string) {
+	if o.SSECustomerKey == "" {
+		return nil, nil
+	}
+
+	return aws.String(sseCustomerAlgorithm), aws.String(o.SSECustomerKey)
+}
+
+// tagging encodes the tags of the options as a url query string.
+func (o PutOptions) tagging() string {
+	values := url.Values{}
+	for k, v := range o.Tagging {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
+}
+
+// optionalString returns nil for an empty string so that unset options are left out of requests.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
 }
 
 // FileUrl takes a path and formats its to a url to the corresponding file.
@@ -95,6 +745,16 @@ func (fs *S3FileSystem) FileUrl(path string) string {
 type S3Caller interface {
 	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
 	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error)
+	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+	CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error)
+	CreateMultipartUpload(input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error)
+	PresignGetObject(input *s3.GetObjectInput, expires time.Duration) (string, error)
 	NewSvc(cfgs ...*aws.Config) S3Caller
 }
 
@@ -121,6 +781,57 @@ func (s *S3Call) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error
 	return s.svc.GetObject(input)
 }
 
+// PresignGetObject creates a url which downloads an object without credentials until it expires.
+func (s *S3Call) PresignGetObject(input *s3.GetObjectInput, expires time.Duration) (string, error) {
+	req, _ := s.svc.GetObjectRequest(input)
+	return req.Presign(expires)
+}
+
+// HeadObject gets the metadata of an object from the s3 api using an HeadObjectInput struct.
+func (s *S3Call) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return s.svc.HeadObject(input)
+}
+
+// ListObjectsV2 lists objects from the s3 api using an ListObjectsV2Input struct.
+func (s *S3Call) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	return s.svc.ListObjectsV2(input)
+}
+
+// ListObjectVersions lists object versions from the s3 api using an ListObjectVersionsInput struct.
+func (s *S3Call) ListObjectVersions(input *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	return s.svc.ListObjectVersions(input)
+}
+
+// DeleteObject removes an object from s3 using an DeleteObjectInput struct.
+func (s *S3Call) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	return s.svc.DeleteObject(input)
+}
+
+// CopyObject copies an object on the s3 api using an CopyObjectInput struct.
+func (s *S3Call) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	return s.svc.CopyObject(input)
+}
+
+// CreateMultipartUpload starts a multipart upload on the s3 api using an CreateMultipartUploadInput struct.
+func (s *S3Call) CreateMultipartUpload(input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	return s.svc.CreateMultipartUpload(input)
+}
+
+// UploadPart uploads a part of a multipart upload on the s3 api using an UploadPartInput struct.
+func (s *S3Call) UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	return s.svc.UploadPart(input)
+}
+
+// CompleteMultipartUpload completes a multipart upload from its parts on the s3 api using an CompleteMultipartUploadInput struct.
+func (s *S3Call) CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	return s.svc.CompleteMultipartUpload(input)
+}
+
+// AbortMultipartUpload aborts a multipart upload, removing the uploaded parts on the s3 api using an AbortMultipartUploadInput struct.
+func (s *S3Call) AbortMultipartUpload(input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	return s.svc.AbortMultipartUpload(input)
+}
+
 // S3File conforms to the File interface defining all of the generic file handling.
 type S3File struct {
 	r    io.ReadSeeker
@@ -129,16 +840,23 @@ type S3File struct {
 }
 
 // NewS3File is a contruct function to generate a s3 file pointer.
-// It takes the contents that the file holds aswell and the path to its location on s3
+// It takes the contents that the file holds aswell and the key of its location on s3
 // as well as mod which represents the time modified, the final argument is the s3 filesystem
 // that the File was created under, this is used for functions such as Create
-func NewS3File(contents []byte, path string, mod *time.Time, fs *S3FileSystem) *S3File {
+func NewS3File(contents []byte, key string, mod *time.Time, fs *S3FileSystem) *S3File {
+	var url string
+	if fs != nil {
+		url = fs.FileUrl(key)
+	}
+
 	return &S3File{
 		bytes.NewReader(contents),
 		&S3FileInfo{
-			path,
-			contents,
-			mod,
+			key:     key,
+			url:     url,
+			content: contents,
+			size:    int64(len(contents)),
+			mod:     mod,
 		},
 		fs,
 	}
@@ -164,30 +882,52 @@ func (s *S3File) Seek(offset int64, whence int) (int64, error) {
 	return s.r.Seek(offset, whence)
 }
 
+// URL returns the public url of the s3 object.
+func (s *S3File) URL() string {
+	return s.info.URL()
+}
+
 // Write writes bytes to the path location by calling the implanted filesystem.
 func (s *S3File) Write(p []byte) (n int, err error) {
 	read := len(p)
 
-	info, _ := s.Stat()
-	_, err = s.fs.Put(bytes.NewReader(p), info.Name())
+	_, err = s.fs.Put(bytes.NewReader(p), s.info.Key())
 	return read, err
 }
 
-// S3FileInfo is A struct which conforms to the file interface which provides information about the s3 file.
+// S3FileInfo is A struct which conforms to the FileInfo interface which provides information about the s3 file.
 type S3FileInfo struct {
-	path    string
-	content []byte
-	mod     *time.Time
+	key             string
+	url             string
+	content         []byte
+	size            int64
+	mod             *time.Time
+	etag            string
+	contentType     string
+	contentEncoding string
+	storageClass    string
+	version         string
+	metadata        map[string]string
 }
 
-// Name gets the base path of the file.
+// Name gets the base name of the file.
 func (s *S3FileInfo) Name() string {
-	return s.path
+	return path.Base(s.key)
+}
+
+// Key returns the full s3 key of the file.
+func (s *S3FileInfo) Key() string {
+	return s.key
+}
+
+// URL returns the public url of the file.
+func (s *S3FileInfo) URL() string {
+	return s.url
 }
 
 // Size returns the length in bytes of the file.
 func (s *S3FileInfo) Size() int64 {
-	return int64(len(s.content))
+	return s.size
 }
 
 // IsDir returns false as s3 file is assumed not to be a directory.
@@ -209,3 +949,44 @@ func (s *S3FileInfo) Sys() interface{} {
 func (s *S3FileInfo) ModTime() time.Time {
 	return *s.mod
 }
+
+// ETag returns the entity tag s3 holds for the object.
+func (s *S3FileInfo) ETag() string {
+	return s.etag
+}
+
+// ContentType returns the mime type the object was stored with.
+func (s *S3FileInfo) ContentType() string {
+	return s.contentType
+}
+
+// ContentEncoding returns the content encoding the object was stored with.
+func (s *S3FileInfo) ContentEncoding() string {
+	return s.contentEncoding
+}
+
+// StorageClass returns the storage class of the object, empty for the default class.
+func (s *S3FileInfo) StorageClass() string {
+	return s.storageClass
+}
+
+// Checksum returns the hex encoded md5 of the object contents, empty if the info came from Stat
+// and the contents were not downloaded.
+func (s *S3FileInfo) Checksum() string {
+	if s.content == nil {
+		return ""
+	}
+
+	sum := md5.Sum(s.content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Version returns the s3 version id of the object, empty if the bucket is not versioned.
+func (s *S3FileInfo) Version() string {
+	return s.version
+}
+
+// Metadata returns the user defined metadata stored with the object.
+func (s *S3FileInfo) Metadata() map[string]string {
+	return s.metadata
+}
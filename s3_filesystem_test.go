@@ -3,12 +3,15 @@ package gofile
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -36,6 +39,10 @@ func TestGetReturnsAnFileWithUrlOfFileAndLastModified(t *testing.T) {
 	response := new(s3.GetObjectOutput)
 	response.LastModified = &now
 	response.Body = recorder.Result().Body
+	response.ETag = aws.String("\"etag\"")
+	response.ContentType = aws.String("image/jpeg")
+	response.VersionId = aws.String("v1")
+	response.Metadata = map[string]*string{"owner": aws.String("me")}
 
 	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
 	caller.On("GetObject", params).Return(response, nil)
@@ -43,9 +50,17 @@ func TestGetReturnsAnFileWithUrlOfFileAndLastModified(t *testing.T) {
 	file, err := fs.Get(path)
 	assert.Nil(t, err)
 
-	info, _ := file.Stat()
-	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, info.Name())
+	info, _ := Stat(file)
+	assert.Equal(t, "file.jpg", info.Name())
+	assert.Equal(t, path, info.(*S3FileInfo).Key())
+	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, info.(*S3FileInfo).URL())
+	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, file.(URLer).URL())
 	assert.Equal(t, now, info.ModTime())
+	assert.Equal(t, "\"etag\"", info.ETag())
+	assert.Equal(t, "image/jpeg", info.ContentType())
+	assert.Equal(t, "v1", info.Version())
+	assert.Equal(t, map[string]string{"owner": "me"}, info.Metadata())
+	assert.Equal(t, "328c30fae61cd119cd177c061d1ac11f", info.Checksum())
 
 	b, _ := ioutil.ReadAll(file)
 	assert.Equal(t, []byte(body), b)
@@ -73,15 +88,26 @@ func TestPutCallsS3AndWrapsReponseInFile(t *testing.T) {
 	now := time.Now()
 	timer.On("Now").Return(now)
 
+	response := &s3.PutObjectOutput{
+		ETag:      aws.String("\"etag\""),
+		VersionId: aws.String("v2"),
+	}
+
 	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
-	caller.On("PutObject", params).Return(nil, nil)
+	caller.On("PutObject", params).Return(response, nil)
 
 	file, err := fs.Put(bytes.NewReader(content), path)
 	assert.Nil(t, err)
 
-	info, _ := file.Stat()
-	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, info.Name())
+	info, _ := Stat(file)
+	assert.Equal(t, "file.jpg", info.Name())
+	assert.Equal(t, path, info.(*S3FileInfo).Key())
+	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, info.(*S3FileInfo).URL())
+	assert.Equal(t, "https://s3-"+region+".amazonaws.com/"+bucket+"/"+path, file.(URLer).URL())
 	assert.Equal(t, now, info.ModTime())
+	assert.Equal(t, "\"etag\"", info.ETag())
+	assert.Equal(t, "image/jpeg", info.ContentType())
+	assert.Equal(t, "v2", info.Version())
 
 	b, _ := ioutil.ReadAll(file)
 	assert.Equal(t, content, b)
@@ -131,9 +157,639 @@ func setUpS3FileSystem(bucket string, config *aws.Config) (*S3FileSystem, *MockS
 	timer := new(MockTime)
 
 	return &S3FileSystem{
-		bucket,
-		config,
-		caller,
-		timer,
+		bucket: bucket,
+		config: config,
+		caller: caller,
+		time:   timer,
 	}, caller, timer
 }
+
+func TestS3FileWritePutsToKey(t *testing.T) {
+	bucket := "bucket"
+	region := "region"
+	config := getConfig(region)
+
+	path := "some/file.txt"
+	content := []byte("new content")
+
+	params := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(path),
+		Body:          bytes.NewReader(content),
+		ContentLength: aws.Int64(int64(len(content))),
+		ContentType:   aws.String("text/plain; charset=utf-8"),
+	}
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("PutObject", params).Return(nil, nil)
+
+	now := time.Now()
+	file := NewS3File([]byte("old content"), path, &now, fs)
+
+	n, err := file.Write(content)
+	assert.Nil(t, err)
+	assert.Equal(t, len(content), n)
+	caller.AssertExpectations(t)
+}
+
+func TestVersionsPagesThroughListAndFiltersKey(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	older := time.Unix(100, 0)
+	newer := time.Unix(200, 0)
+
+	first := &s3.ListObjectVersionsOutput{
+		IsTruncated:         aws.Bool(true),
+		NextKeyMarker:       aws.String(path),
+		NextVersionIdMarker: aws.String("v1"),
+		Versions: []*s3.ObjectVersion{
+			{Key: aws.String(path), VersionId: aws.String("v1"), IsLatest: aws.Bool(false), LastModified: &older, Size: aws.Int64(3)},
+			{Key: aws.String(path + ".bak"), VersionId: aws.String("other"), LastModified: &older},
+		},
+	}
+	second := &s3.ListObjectVersionsOutput{
+		IsTruncated: aws.Bool(false),
+		DeleteMarkers: []*s3.DeleteMarkerEntry{
+			{Key: aws.String(path), VersionId: aws.String("v2"), IsLatest: aws.Bool(true), LastModified: &newer},
+		},
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("ListObjectVersions", &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(path),
+	}).Return(first, nil).Once()
+	caller.On("ListObjectVersions", &s3.ListObjectVersionsInput{
+		Bucket:          aws.String(bucket),
+		Prefix:          aws.String(path),
+		KeyMarker:       aws.String(path),
+		VersionIdMarker: aws.String("v1"),
+	}).Return(second, nil).Once()
+
+	versions, err := fs.Versions(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []Version{
+		{Key: path, ID: "v2", IsLatest: true, DeleteMarker: true, ModTime: newer},
+		{Key: path, ID: "v1", Size: 3, ModTime: older},
+	}, versions)
+}
+
+func TestGetVersionRequestsVersionId(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	recorder := httptest.NewRecorder()
+	recorder.WriteString("old body")
+
+	now := time.Now()
+	response := &s3.GetObjectOutput{
+		Body:         recorder.Result().Body,
+		LastModified: &now,
+		VersionId:    aws.String("v1"),
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("GetObject", &s3.GetObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(path),
+		VersionId: aws.String("v1"),
+	}).Return(response, nil)
+
+	file, err := fs.GetVersion(path, "v1")
+	assert.Nil(t, err)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "v1", info.Version())
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, []byte("old body"), b)
+}
+
+func TestDeleteVersionDeletesVersionId(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("DeleteObject", &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(path),
+		VersionId: aws.String("v1"),
+	}).Return(new(s3.DeleteObjectOutput), nil)
+
+	assert.Nil(t, fs.DeleteVersion(path, "v1"))
+	caller.AssertExpectations(t)
+}
+
+func TestRestoreVersionCopiesVersionOverKey(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	recorder := httptest.NewRecorder()
+	recorder.WriteString("old body")
+
+	now := time.Now()
+	response := &s3.GetObjectOutput{
+		Body:         recorder.Result().Body,
+		LastModified: &now,
+		VersionId:    aws.String("v3"),
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("CopyObject", &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(path),
+		CopySource: aws.String("bucket%2Fsome%2Ffile.jpg?versionId=v1"),
+	}).Return(&s3.CopyObjectOutput{VersionId: aws.String("v3")}, nil)
+	caller.On("GetObject", &s3.GetObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(path),
+		VersionId: aws.String("v3"),
+	}).Return(response, nil)
+
+	file, err := fs.RestoreVersion(path, "v1")
+	assert.Nil(t, err)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "v3", info.Version())
+}
+
+func TestPutWithOptionsMergesDefaultOptions(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "archive/file.json"
+	content := []byte("{}")
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	fs.SetDefaultPutOptions(PutOptions{
+		SSEKMSKeyId:  "key-id",
+		StorageClass: "STANDARD",
+		Tagging:      map[string]string{"team": "data"},
+	})
+
+	params := &s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(path),
+		Body:                 bytes.NewReader(content),
+		ContentLength:        aws.Int64(int64(len(content))),
+		ContentType:          aws.String("application/json"),
+		ACL:                  aws.String("private"),
+		StorageClass:         aws.String("GLACIER_IR"),
+		ServerSideEncryption: aws.String("aws:kms"),
+		SSEKMSKeyId:          aws.String("key-id"),
+		Tagging:              aws.String("retention=long&team=data"),
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("PutObject", params).Return(nil, nil)
+
+	_, err := fs.PutWithOptions(bytes.NewReader(content), path, PutOptions{
+		ACL:          "private",
+		StorageClass: "GLACIER_IR",
+		Tagging:      map[string]string{"retention": "long"},
+	})
+	assert.Nil(t, err)
+	caller.AssertExpectations(t)
+}
+
+func TestPutWithOptionsOverridesContentType(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "archive/file.bin"
+	content := []byte("{}")
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	params := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(path),
+		Body:          bytes.NewReader(content),
+		ContentLength: aws.Int64(int64(len(content))),
+		ContentType:   aws.String("application/json"),
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("PutObject", params).Return(nil, nil)
+
+	file, err := fs.PutWithOptions(bytes.NewReader(content), path, PutOptions{ContentType: "application/json"})
+	assert.Nil(t, err)
+	caller.AssertExpectations(t)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "application/json", info.ContentType())
+}
+
+func TestGetSendsDefaultCustomerKey(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+	key := "01234567890123456789012345678901"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+	fs.SetDefaultPutOptions(PutOptions{SSECustomerKey: key})
+
+	recorder := httptest.NewRecorder()
+	now := time.Now()
+	response := &s3.GetObjectOutput{
+		Body:         recorder.Result().Body,
+		LastModified: &now,
+	}
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("GetObject", &s3.GetObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(path),
+		SSECustomerAlgorithm: aws.String("AES256"),
+		SSECustomerKey:       aws.String(key),
+	}).Return(response, nil)
+
+	_, err := fs.Get(path)
+	assert.Nil(t, err)
+	caller.AssertExpectations(t)
+}
+
+func TestRestoreVersionCopiesWithDefaultOptions(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+	fs.SetDefaultPutOptions(PutOptions{
+		ServerSideEncryption: "AES256",
+		StorageClass:         "STANDARD_IA",
+		Tagging:              map[string]string{"team": "data"},
+	})
+
+	e := errors.New("copy failed")
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("CopyObject", &s3.CopyObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(path),
+		CopySource:           aws.String("bucket%2Fsome%2Ffile.jpg?versionId=v1"),
+		StorageClass:         aws.String("STANDARD_IA"),
+		ServerSideEncryption: aws.String("AES256"),
+		Tagging:              aws.String("team=data"),
+		TaggingDirective:     aws.String("REPLACE"),
+	}).Return(nil, e)
+
+	_, err := fs.RestoreVersion(path, "v1")
+	assert.Equal(t, e, err)
+}
+
+func TestDeleteDeletesKey(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("DeleteObject", &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	}).Return(new(s3.DeleteObjectOutput), nil)
+
+	assert.Nil(t, Delete(fs, path))
+	caller.AssertExpectations(t)
+}
+
+func TestPutSendsConfiguredChecksum(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.txt"
+	content := []byte("some content")
+
+	for algo, set := range map[ChecksumAlgorithm]func(*s3.PutObjectInput){
+		ChecksumMD5: func(p *s3.PutObjectInput) {
+			p.ContentMD5 = aws.String("mJNTIjPK/5jNCDoRawE8Cw==")
+		},
+		ChecksumSHA256: func(p *s3.PutObjectInput) {
+			p.ChecksumAlgorithm = aws.String("SHA256")
+			p.ChecksumSHA256 = aws.String("KQ9JPET11j0Gs3TQpavSkvrji5LKsvrl7+/hsOk0f1Y=")
+		},
+	} {
+		fs, caller, timer := setUpS3FileSystem(bucket, config)
+		fs.SetChecksum(algo, false)
+		timer.On("Now").Return(time.Now())
+
+		params := &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(path),
+			Body:          bytes.NewReader(content),
+			ContentLength: aws.Int64(int64(len(content))),
+			ContentType:   aws.String("text/plain; charset=utf-8"),
+		}
+		set(params)
+
+		caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+		caller.On("PutObject", params).Return(nil, nil)
+
+		_, err := fs.Put(bytes.NewReader(content), path)
+		assert.Nil(t, err)
+		caller.AssertExpectations(t)
+	}
+}
+
+func TestGetVerifiesChecksum(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.txt"
+
+	responses := map[*s3.GetObjectOutput]error{
+		{ETag: aws.String("\"9893532233caff98cd083a116b013c0b\"")}:                   nil,
+		{ETag: aws.String("\"00000000000000000000000000000000\"")}:                   ErrChecksumMismatch,
+		{ETag: aws.String("\"00000000000000000000000000000000-2\"")}:                 nil,
+		{ChecksumSHA256: aws.String("KQ9JPET11j0Gs3TQpavSkvrji5LKsvrl7+/hsOk0f1Y=")}: nil,
+		{ChecksumSHA256: aws.String("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")}: ErrChecksumMismatch,
+	}
+
+	for response, expected := range responses {
+		fs, caller, _ := setUpS3FileSystem(bucket, config)
+		fs.SetChecksum(ChecksumMD5, true)
+
+		recorder := httptest.NewRecorder()
+		recorder.WriteString("some content")
+		now := time.Now()
+		response.Body = recorder.Result().Body
+		response.LastModified = &now
+
+		caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+		caller.On("GetObject", &s3.GetObjectInput{
+			Bucket:       aws.String(bucket),
+			Key:          aws.String(path),
+			ChecksumMode: aws.String("ENABLED"),
+		}).Return(response, nil)
+
+		_, err := fs.Get(path)
+		assert.Equal(t, expected, err)
+	}
+}
+
+func TestGetSkipsCompositeChecksumsOfMultipartObjects(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "videos/big.mp4"
+
+	responses := []*s3.GetObjectOutput{
+		{ChecksumSHA256: aws.String("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=-3"), ETag: aws.String("\"00000000000000000000000000000000-3\"")},
+		{ChecksumCRC32C: aws.String("AAAAAA==-2"), ETag: aws.String("\"00000000000000000000000000000000-2\"")},
+	}
+
+	for _, response := range responses {
+		fs, caller, _ := setUpS3FileSystem(bucket, config)
+		fs.SetChecksum(ChecksumSHA256, true)
+
+		recorder := httptest.NewRecorder()
+		recorder.WriteString("some content")
+		now := time.Now()
+		response.Body = recorder.Result().Body
+		response.LastModified = &now
+
+		caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+		caller.On("GetObject", &s3.GetObjectInput{
+			Bucket:       aws.String(bucket),
+			Key:          aws.String(path),
+			ChecksumMode: aws.String("ENABLED"),
+		}).Return(response, nil)
+
+		file, err := fs.Get(path)
+		assert.Nil(t, err)
+
+		b, _ := ioutil.ReadAll(file)
+		assert.Equal(t, "some content", string(b))
+	}
+}
+
+func TestListPagesThroughKeys(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("ListObjectsV2", &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String("dir/"),
+	}).Return(&s3.ListObjectsV2Output{
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("token"),
+		Contents:              []*s3.Object{{Key: aws.String("dir/b.txt")}},
+	}, nil).Once()
+	caller.On("ListObjectsV2", &s3.ListObjectsV2Input{
+		Bucket:            aws.String(bucket),
+		Prefix:            aws.String("dir/"),
+		ContinuationToken: aws.String("token"),
+	}).Return(&s3.ListObjectsV2Output{
+		IsTruncated: aws.Bool(false),
+		Contents:    []*s3.Object{{Key: aws.String("dir/a.txt")}},
+	}, nil).Once()
+
+	keys, err := fs.List("dir/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir/a.txt", "dir/b.txt"}, keys)
+}
+
+func TestStatSendsHeadObject(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "some/file.jpg"
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+	mod := time.Unix(100, 0)
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("HeadObject", &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	}).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(42),
+		ContentType:   aws.String("image/jpeg"),
+		ETag:          aws.String("\"etag\""),
+		LastModified:  &mod,
+	}, nil)
+
+	info, err := fs.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "file.jpg", info.Name())
+	assert.Equal(t, int64(42), info.Size())
+	assert.Equal(t, "\"etag\"", info.ETag())
+	assert.Equal(t, "image/jpeg", info.ContentType())
+	assert.Equal(t, mod, info.ModTime())
+	assert.Equal(t, "", info.Checksum())
+}
+
+func TestPutDetectsContentTypeOfExtensionlessKey(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "avatars/123"
+
+	params := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(path),
+		Body:          bytes.NewReader(pngHeader),
+		ContentLength: aws.Int64(int64(len(pngHeader))),
+		ContentType:   aws.String("image/png"),
+	}
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("PutObject", params).Return(&s3.PutObjectOutput{ETag: aws.String("\"etag\"")}, nil)
+
+	file, err := fs.Put(bytes.NewReader(pngHeader), path)
+	assert.Nil(t, err)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "image/png", info.ContentType())
+}
+
+func TestPutStreamUploadsLargeContentsInParts(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "videos/big.mp4"
+	content := bytes.Repeat([]byte("a"), multipartPartSize+10)
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("CreateMultipartUpload", &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(path),
+		ContentType: aws.String("video/mp4"),
+	}).Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+
+	for number, size := range map[int64]int64{1: multipartPartSize, 2: 10} {
+		number, size := number, size
+		caller.On("UploadPart", mock.MatchedBy(func(input *s3.UploadPartInput) bool {
+			return aws.Int64Value(input.PartNumber) == number &&
+				aws.Int64Value(input.ContentLength) == size &&
+				aws.StringValue(input.UploadId) == "upload"
+		})).Return(&s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("\"part%d\"", number))}, nil).Once()
+	}
+
+	caller.On("CompleteMultipartUpload", &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(path),
+		UploadId: aws.String("upload"),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{
+			{ETag: aws.String("\"part1\""), PartNumber: aws.Int64(1)},
+			{ETag: aws.String("\"part2\""), PartNumber: aws.Int64(2)},
+		}},
+	}).Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String("\"etag-2\""), VersionId: aws.String("v1")}, nil)
+
+	file, err := PutStream(fs, ioutil.NopCloser(bytes.NewReader(content)), path, PutOptions{})
+	assert.Nil(t, err)
+
+	info, _ := Stat(file)
+	assert.Equal(t, int64(len(content)), info.Size())
+	assert.Equal(t, "\"etag-2\"", info.ETag())
+	assert.Equal(t, "v1", info.Version())
+	caller.AssertExpectations(t)
+}
+
+func TestPutStreamAbortsUploadWhenSourceFails(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "videos/big.mp4"
+	readErr := errors.New("decode failed")
+	src := io.MultiReader(bytes.NewReader(make([]byte, multipartPartSize)), &failingReader{readErr})
+
+	fs, caller, _ := setUpS3FileSystem(bucket, config)
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("CreateMultipartUpload", mock.Anything).Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil)
+	caller.On("UploadPart", mock.Anything).Return(&s3.UploadPartOutput{ETag: aws.String("\"part1\"")}, nil)
+	caller.On("AbortMultipartUpload", &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(path),
+		UploadId: aws.String("upload"),
+	}).Return(&s3.AbortMultipartUploadOutput{}, nil)
+
+	_, err := fs.PutStream(src, path, PutOptions{})
+	assert.Equal(t, readErr, err)
+	caller.AssertExpectations(t)
+	caller.AssertNotCalled(t, "CompleteMultipartUpload", mock.Anything)
+}
+
+func TestPutStreamPutsSmallContentsWithSingleRequest(t *testing.T) {
+	bucket := "bucket"
+	config := getConfig("region")
+	path := "avatars/123.png"
+
+	params := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(path),
+		Body:          bytes.NewReader(pngHeader),
+		ContentLength: aws.Int64(int64(len(pngHeader))),
+		ContentType:   aws.String("image/png"),
+	}
+
+	fs, caller, timer := setUpS3FileSystem(bucket, config)
+	timer.On("Now").Return(time.Now())
+
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+	caller.On("PutObject", params).Return(&s3.PutObjectOutput{ETag: aws.String("\"etag\"")}, nil)
+
+	_, err := fs.PutStream(ioutil.NopCloser(bytes.NewReader(pngHeader)), path, PutOptions{})
+	assert.Nil(t, err)
+	caller.AssertNotCalled(t, "CreateMultipartUpload", mock.Anything)
+}
+
+func TestPutDoesNotUploadWhenSourceFails(t *testing.T) {
+	config := getConfig("region")
+	readErr := errors.New("connection reset")
+	src := struct {
+		io.Reader
+		io.Seeker
+	}{io.MultiReader(bytes.NewReader(make([]byte, 10)), &failingReader{readErr}), bytes.NewReader(nil)}
+
+	fs, caller, _ := setUpS3FileSystem("bucket", config)
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+
+	_, err := fs.Put(src, "some/file.jpg")
+	assert.Equal(t, readErr, err)
+	caller.AssertNotCalled(t, "PutObject", mock.Anything)
+}
+
+func TestPutStreamDoesNotUploadWhenSmallSourceFails(t *testing.T) {
+	config := getConfig("region")
+	readErr := errors.New("connection reset")
+	src := io.MultiReader(bytes.NewReader(make([]byte, 10)), &failingReader{readErr})
+
+	fs, caller, _ := setUpS3FileSystem("bucket", config)
+	caller.On("NewSvc", []*aws.Config{config}).Return(caller)
+
+	_, err := fs.PutStream(src, "some/file.jpg", PutOptions{})
+	assert.Equal(t, readErr, err)
+	caller.AssertNotCalled(t, "PutObject", mock.Anything)
+}
+
+// failingReader fails every read with err.
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
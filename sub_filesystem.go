@@ -0,0 +1,236 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrPathEscape is returned when a path uses ".." to reach outside of the prefix of a sub
+// file system or the root of a file system cleaning paths with a PathPolicy.
+var ErrPathEscape = errors.New("gofile: path escapes the sub file system")
+
+// SubFileSystem scopes a FileSystem to the files under a prefix, see Sub.
+type SubFileSystem struct {
+	fs     FileSystem
+	prefix string
+}
+
+// Sub returns a FileSystem holding the files of fs under prefix. The prefix is added to every
+// path passed in and stripped from every path returned, including listings and the key and
+// name of file info, so the returned file system can be handed out without exposing the
+// rest of fs. Paths containing ".." are rejected with ErrPathEscape.
+func Sub(fs FileSystem, prefix string) FileSystem {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &SubFileSystem{
+		fs,
+		prefix,
+	}
+}
+
+// Put writes the file to the wrapped file system under the prefix.
+func (s *SubFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	full, err := s.resolve("put", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.fs.Put(src, full)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newFile(file, full), nil
+}
+
+// PutWithOptions writes the file to the wrapped file system under the prefix with the given options.
+func (s *SubFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	full, err := s.resolve("put", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := PutWithOptions(s.fs, src, full, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newFile(file, full), nil
+}
+
+// Get returns the file at path under the prefix.
+func (s *SubFileSystem) Get(path string) (File, error) {
+	full, err := s.resolve("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.fs.Get(full)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newFile(file, full), nil
+}
+
+// Stat returns the info of the file at path under the prefix.
+func (s *SubFileSystem) Stat(path string) (FileInfo, error) {
+	full, err := s.resolve("stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := StatPath(s.fs, full)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subFileInfo{info, s.relativeKey(full)}, nil
+}
+
+// Delete removes the file at path under the prefix.
+func (s *SubFileSystem) Delete(path string) error {
+	full, err := s.resolve("delete", path)
+	if err != nil {
+		return err
+	}
+
+	return Delete(s.fs, full)
+}
+
+// List returns the paths under the prefix starting with prefix, relative to the sub file system.
+func (s *SubFileSystem) List(prefix string) ([]string, error) {
+	full, err := s.resolve("list", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := List(s.fs, full)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPrefix := s.keyPrefix()
+	for i, p := range paths {
+		paths[i] = strings.TrimPrefix(p, keyPrefix)
+	}
+
+	return paths, nil
+}
+
+// CleanPath returns the key the wrapped file system stores path at, relative to the prefix.
+// Policies clean each part of a path on its own, so the relative path is cleaned alone.
+func (s *SubFileSystem) CleanPath(path string) (string, error) {
+	if _, err := s.resolve("clean", path); err != nil {
+		return "", err
+	}
+
+	return CleanPath(s.fs, s.relative(path))
+}
+
+// resolve checks that p stays inside the sub file system and returns its path in the
+// wrapped file system.
+func (s *SubFileSystem) resolve(op, p string) (string, error) {
+	parts := strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+
+	for _, part := range parts {
+		if part == ".." {
+			return "", &os.PathError{Op: op, Path: p, Err: ErrPathEscape}
+		}
+	}
+
+	return s.prefix + s.relative(p), nil
+}
+
+// relative returns p without a leading slash, paths are always relative to the prefix.
+func (s *SubFileSystem) relative(p string) string {
+	return strings.TrimLeft(p, "/")
+}
+
+// keyPrefix returns the prefix as the wrapped file system cleans it, which is what the keys
+// it returns start with.
+func (s *SubFileSystem) keyPrefix() string {
+	if s.prefix == "" {
+		return ""
+	}
+
+	prefix, err := CleanPath(s.fs, strings.TrimSuffix(s.prefix, "/"))
+	if err != nil {
+		return s.prefix
+	}
+
+	return prefix + "/"
+}
+
+// relativeKey returns the key the wrapped file system stores full at, relative to the prefix.
+func (s *SubFileSystem) relativeKey(full string) string {
+	key, err := CleanPath(s.fs, full)
+	if err != nil {
+		return strings.TrimPrefix(full, s.prefix)
+	}
+
+	return strings.TrimPrefix(key, s.keyPrefix())
+}
+
+// newFile wraps the file stored at full in the wrapped file system so that it reports the
+// key relative to the prefix.
+func (s *SubFileSystem) newFile(file File, full string) *SubFile {
+	return &SubFile{
+		file,
+		s.relativeKey(full),
+		s,
+	}
+}
+
+// SubFile is a File held by a SubFileSystem.
+type SubFile struct {
+	File
+	key string
+	fs  *SubFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *SubFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the sub file system.
+func (f *SubFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.key)
+	return len(p), err
+}
+
+// Stat returns the info of the wrapped file with the key relative to the sub file system.
+func (f *SubFile) Stat() (os.FileInfo, error) {
+	info, err := Stat(f.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subFileInfo{info, f.key}, nil
+}
+
+// subFileInfo reports the key of a file relative to a sub file system.
+type subFileInfo struct {
+	FileInfo
+	key string
+}
+
+// Name returns the base name of the file.
+func (i *subFileInfo) Name() string {
+	return path.Base(i.key)
+}
+
+// Key returns the path of the file relative to the sub file system.
+func (i *subFileInfo) Key() string {
+	return i.key
+}
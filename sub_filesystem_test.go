@@ -0,0 +1,102 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubFileSystemAddsPrefix(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := Sub(mem, "tenant-123/")
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "uploads/file.txt")
+	assert.Nil(t, err)
+
+	file, err := mem.Get("tenant-123/uploads/file.txt")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "contents", string(b))
+
+	file, err = fs.Get("uploads/file.txt")
+	assert.Nil(t, err)
+	b, _ = ioutil.ReadAll(file)
+	assert.Equal(t, "contents", string(b))
+
+	info, _ := file.Stat()
+	assert.Equal(t, "file.txt", info.Name())
+	assert.Equal(t, "uploads/file.txt", info.(interface{ Key() string }).Key())
+}
+
+func TestSubFileSystemListStripsPrefix(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("a")), "tenant-1/uploads/a.txt")
+	mem.Put(bytes.NewReader([]byte("b")), "tenant-1/b.txt")
+	mem.Put(bytes.NewReader([]byte("c")), "tenant-10/uploads/c.txt")
+	fs := Sub(mem, "tenant-1")
+
+	paths, err := List(fs, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.txt", "uploads/a.txt"}, paths)
+
+	paths, err = List(fs, "uploads/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"uploads/a.txt"}, paths)
+}
+
+func TestSubFileSystemBlocksEscapes(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("secret")), "tenant-2/secret.txt")
+	fs := Sub(mem, "tenant-1")
+
+	_, err := fs.Get("../tenant-2/secret.txt")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("x")), "uploads/../../x.txt")
+	assert.Equal(t, ErrPathEscape, err.(*os.PathError).Err)
+
+	assert.NotNil(t, Delete(fs, ".."))
+
+	_, err = mem.Get("x.txt")
+	assert.True(t, IsNotExist(err))
+}
+
+func TestSubFileSystemCleansPathsWithWrappedPolicy(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.SetPathPolicy(PathPolicy{Sanitize: true, Lowercase: true})
+	fs := Sub(mem, "tenants/a")
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "My Docs/File.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "contents", readAll(t, mem, "tenants/a/my-docs/file.txt"))
+	assert.Equal(t, "contents", readAll(t, fs, "My Docs/File.txt"))
+
+	key, err := CleanPath(fs, "My Docs/File.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "my-docs/file.txt", key)
+}
+
+func TestSubFileSystemReportsCleanedKeys(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	mem.SetPathPolicy(PathPolicy{Sanitize: true, Lowercase: true})
+	fs := Sub(mem, "Tenants/A")
+
+	file, err := fs.Put(bytes.NewReader([]byte("contents")), "My Docs/File.txt")
+	assert.Nil(t, err)
+
+	info, _ := Stat(file)
+	assert.Equal(t, "my-docs/file.txt", info.(interface{ Key() string }).Key())
+
+	info, err = StatPath(fs, "My Docs/File.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "my-docs/file.txt", info.(interface{ Key() string }).Key())
+	assert.Equal(t, "file.txt", info.Name())
+
+	paths, err := List(fs, "My Docs/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-docs/file.txt"}, paths)
+}
@@ -0,0 +1,292 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleLimits are the rates a ThrottledFileSystem allows, a zero rate is unlimited.
+type ThrottleLimits struct {
+	// OpsPerSecond limits the number of operations started each second.
+	OpsPerSecond float64
+
+	// ReadBytesPerSecond limits how fast files are read.
+	ReadBytesPerSecond float64
+
+	// WriteBytesPerSecond limits how fast the source of a Put is streamed.
+	WriteBytesPerSecond float64
+}
+
+// ThrottledFileSystem limits the rate of operations and the bandwidth used by a wrapped
+// file system. A global limit applies to every path and limits can be set for paths under
+// a prefix, an operation waits for both the global limit and the limit of the longest
+// matching prefix. Limits can be changed at any time.
+type ThrottledFileSystem struct {
+	fs    FileSystem
+	time  Time
+	sleep func(d time.Duration)
+
+	mu       sync.RWMutex
+	global   *throttle
+	prefixes map[string]*throttle
+}
+
+// NewThrottledFileSystem is a construct function which throttles fs to the global limits.
+func NewThrottledFileSystem(fs FileSystem, limits ThrottleLimits) *ThrottledFileSystem {
+	global := new(throttle)
+	global.set(limits)
+
+	return &ThrottledFileSystem{
+		fs:       fs,
+		time:     new(OSTime),
+		sleep:    time.Sleep,
+		global:   global,
+		prefixes: make(map[string]*throttle),
+	}
+}
+
+// SetLimits changes the global limits.
+func (t *ThrottledFileSystem) SetLimits(limits ThrottleLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.global.set(limits)
+}
+
+// SetPrefixLimits sets the limits for paths starting with prefix.
+func (t *ThrottledFileSystem) SetPrefixLimits(prefix string, limits ThrottleLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	th, ok := t.prefixes[prefix]
+	if !ok {
+		th = new(throttle)
+		t.prefixes[prefix] = th
+	}
+
+	th.set(limits)
+}
+
+// RemovePrefixLimits removes the limits for paths starting with prefix.
+func (t *ThrottledFileSystem) RemovePrefixLimits(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.prefixes, prefix)
+}
+
+// Put writes the file, streaming src no faster than the write limit.
+func (t *ThrottledFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return t.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions writes the file with the given options, streaming src no faster than the write limit.
+func (t *ThrottledFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	t.wait(path, opsLimit, 1)
+
+	file, err := PutWithOptions(t.fs, &throttledReader{src, path, t}, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.newFile(file, path), nil
+}
+
+// Get returns the file, reads from which are limited to the read limit.
+func (t *ThrottledFileSystem) Get(path string) (File, error) {
+	t.wait(path, opsLimit, 1)
+
+	file, err := t.fs.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.newFile(file, path), nil
+}
+
+// Stat returns the info of the file.
+func (t *ThrottledFileSystem) Stat(path string) (FileInfo, error) {
+	t.wait(path, opsLimit, 1)
+
+	return StatPath(t.fs, path)
+}
+
+// Delete removes the file.
+func (t *ThrottledFileSystem) Delete(path string) error {
+	t.wait(path, opsLimit, 1)
+
+	return Delete(t.fs, path)
+}
+
+// List lists the files starting with prefix.
+func (t *ThrottledFileSystem) List(prefix string) ([]string, error) {
+	t.wait(prefix, opsLimit, 1)
+
+	return List(t.fs, prefix)
+}
+
+// wait blocks until n of the given limit are available for path under both the global
+// limits and the limits of the longest matching prefix.
+func (t *ThrottledFileSystem) wait(path string, kind limitKind, n int) {
+	if n <= 0 {
+		return
+	}
+
+	t.mu.RLock()
+	throttles := []*throttle{t.global}
+	longest := -1
+	var match *throttle
+	for prefix, th := range t.prefixes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			longest = len(prefix)
+			match = th
+		}
+	}
+	if match != nil {
+		throttles = append(throttles, match)
+	}
+	t.mu.RUnlock()
+
+	now := t.time.Now()
+	var delay time.Duration
+	for _, th := range throttles {
+		if d := th.limiter(kind).reserve(now, float64(n)); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+// newFile wraps a file so that reads from it are throttled.
+func (t *ThrottledFileSystem) newFile(file File, path string) *ThrottledFile {
+	return &ThrottledFile{
+		file,
+		path,
+		t,
+	}
+}
+
+// ThrottledFile is a File read from a ThrottledFileSystem, reads are limited to the read limit.
+type ThrottledFile struct {
+	File
+	path string
+	fs   *ThrottledFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *ThrottledFile) unwrap() File {
+	return f.File
+}
+
+// Read reads from the wrapped file and waits for the bytes read to fit the read limit.
+func (f *ThrottledFile) Read(p []byte) (n int, err error) {
+	n, err = f.File.Read(p)
+	f.fs.wait(f.path, readLimit, n)
+
+	return n, err
+}
+
+// Write replaces the contents of the file by putting p to the throttled file system.
+func (f *ThrottledFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
+
+// throttledReader limits the rate the source of a Put is read at to the write limit.
+type throttledReader struct {
+	io.ReadSeeker
+	path string
+	fs   *ThrottledFileSystem
+}
+
+// Read reads from the source and waits for the bytes read to fit the write limit.
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadSeeker.Read(p)
+	r.fs.wait(r.path, writeLimit, n)
+
+	return n, err
+}
+
+// limitKind selects one of the limiters of a throttle.
+type limitKind int
+
+const (
+	opsLimit limitKind = iota
+	readLimit
+	writeLimit
+)
+
+// throttle holds the limiters for a set of ThrottleLimits.
+type throttle struct {
+	ops   rateLimiter
+	read  rateLimiter
+	write rateLimiter
+}
+
+// set changes the rates of the limiters.
+func (th *throttle) set(limits ThrottleLimits) {
+	th.ops.setRate(limits.OpsPerSecond)
+	th.read.setRate(limits.ReadBytesPerSecond)
+	th.write.setRate(limits.WriteBytesPerSecond)
+}
+
+// limiter returns the limiter of the given kind.
+func (th *throttle) limiter(kind limitKind) *rateLimiter {
+	switch kind {
+	case readLimit:
+		return &th.read
+	case writeLimit:
+		return &th.write
+	default:
+		return &th.ops
+	}
+}
+
+// rateLimiter is a token bucket holding up to a second of tokens. Tokens can be taken
+// faster than they are added, the caller then waits until the debt has been paid back.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// setRate changes the number of tokens added each second, zero disables the limit.
+func (l *rateLimiter) setRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.tokens = math.Min(l.tokens, rate)
+}
+
+// reserve takes n tokens at now and returns how long the caller must wait before using them.
+func (l *rateLimiter) reserve(now time.Time, n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	if l.last.IsZero() {
+		l.tokens = l.rate
+	} else {
+		l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
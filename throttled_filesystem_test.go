@@ -0,0 +1,84 @@
+package gofile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpThrottledFileSystem(limits ThrottleLimits) (*ThrottledFileSystem, *MemoryFileSystem, *[]time.Duration) {
+	mem := NewMemoryFileSystem()
+	mem.Put(bytes.NewReader([]byte("contents")), "file.txt")
+
+	now := time.Unix(1000, 0)
+	var sleeps []time.Duration
+	timer := new(MockTime)
+	timer.On("Now").Return(func() time.Time { return now })
+
+	fs := NewThrottledFileSystem(mem, limits)
+	fs.time = timer
+	fs.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+
+	return fs, mem, &sleeps
+}
+
+func TestThrottledFileSystemLimitsOperations(t *testing.T) {
+	fs, _, sleeps := setUpThrottledFileSystem(ThrottleLimits{OpsPerSecond: 2})
+
+	fs.Get("file.txt")
+	fs.Get("file.txt")
+	assert.Empty(t, *sleeps)
+
+	fs.Get("file.txt")
+	fs.Get("file.txt")
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, *sleeps)
+}
+
+func TestThrottledFileSystemLimitsReadBandwidth(t *testing.T) {
+	fs, _, sleeps := setUpThrottledFileSystem(ThrottleLimits{ReadBytesPerSecond: 4})
+
+	file, err := fs.Get("file.txt")
+	assert.Nil(t, err)
+
+	b, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "contents", string(b))
+	assert.Equal(t, []time.Duration{time.Second}, *sleeps)
+}
+
+func TestThrottledFileSystemLimitsWritesPerPrefix(t *testing.T) {
+	fs, mem, sleeps := setUpThrottledFileSystem(ThrottleLimits{})
+	fs.SetPrefixLimits("exports/", ThrottleLimits{WriteBytesPerSecond: 2})
+
+	_, err := fs.Put(bytes.NewReader([]byte("contents")), "other.txt")
+	assert.Nil(t, err)
+	assert.Empty(t, *sleeps)
+
+	_, err = fs.Put(bytes.NewReader([]byte("contents")), "exports/file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{3 * time.Second}, *sleeps)
+	assert.Equal(t, "contents", readAll(t, mem, "exports/file.txt"))
+
+	fs.RemovePrefixLimits("exports/")
+	fs.Put(bytes.NewReader([]byte("contents")), "exports/file.txt")
+	assert.Len(t, *sleeps, 1)
+}
+
+func TestThrottledFileSystemLimitsCanChangeAtRuntime(t *testing.T) {
+	fs, _, sleeps := setUpThrottledFileSystem(ThrottleLimits{OpsPerSecond: 1})
+
+	fs.Get("file.txt")
+	fs.SetLimits(ThrottleLimits{})
+	fs.Get("file.txt")
+	assert.Empty(t, *sleeps)
+
+	fs.SetLimits(ThrottleLimits{OpsPerSecond: 4})
+	fs.Get("file.txt")
+	fs.Get("file.txt")
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, *sleeps)
+}
@@ -0,0 +1,284 @@
+package gofile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is returned when a file is bigger than the maximum size of a ValidationPolicy.
+	ErrTooLarge = errors.New("gofile: file is larger than the maximum size")
+
+	// ErrTypeNotAllowed is returned when the contents or extension of a file are not allowed by a ValidationPolicy.
+	ErrTypeNotAllowed = errors.New("gofile: file type is not allowed")
+
+	// ErrTypeMismatch is returned when the contents of a file do not match its extension,
+	// for example a .png holding html.
+	ErrTypeMismatch = errors.New("gofile: file contents do not match the extension")
+)
+
+// ValidationPolicy describes the files a ValidatingFileSystem accepts, zero values are not checked.
+// The type of a file is detected from the magic bytes at the start of its contents and is
+// always checked against the type its extension implies, taken from a table built into the
+// package rather than the mime types of the host. Extensions missing from the table are not
+// checked, and every text type is compatible with every other, as text cannot be told apart
+// reliably, so a .txt holding html is accepted.
+type ValidationPolicy struct {
+	// MaxSize is the largest file in bytes.
+	MaxSize int64
+
+	// AllowedTypes lists the mime types files may have, a type ending in "/*" such as
+	// "image/*" allows every subtype.
+	AllowedTypes []string
+
+	// AllowedExtensions lists the extensions, such as ".png", files may have.
+	AllowedExtensions []string
+}
+
+// Validate checks the file which would be put at path with the contents of src against the
+// policy, src is left where it started.
+func (p ValidationPolicy) Validate(src io.ReadSeeker, path string) error {
+	if err := p.checkExtension(path); err != nil {
+		return err
+	}
+
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if p.MaxSize > 0 {
+		end, err := src.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if end-start > p.MaxSize {
+			src.Seek(start, io.SeekStart)
+			return ErrTooLarge
+		}
+	}
+
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	detected, err := DetectContentType(src)
+	if err != nil {
+		return err
+	}
+
+	return p.checkType(detected, path)
+}
+
+// checkExtension checks the extension of path against the allowed extensions.
+func (p ValidationPolicy) checkExtension(name string) error {
+	if len(p.AllowedExtensions) == 0 {
+		return nil
+	}
+
+	ext := strings.ToLower(path.Ext(name))
+	for _, allowed := range p.AllowedExtensions {
+		if strings.ToLower(allowed) == ext {
+			return nil
+		}
+	}
+
+	return ErrTypeNotAllowed
+}
+
+// checkType checks the type detected from the contents against the allowed types and the
+// type implied by the extension of path.
+func (p ValidationPolicy) checkType(detected string, name string) error {
+	detected = mediaType(detected)
+
+	if len(p.AllowedTypes) > 0 {
+		allowed := false
+		for _, t := range p.AllowedTypes {
+			t = strings.ToLower(t)
+			if t == detected || (strings.HasSuffix(t, "/*") && strings.HasPrefix(detected, strings.TrimSuffix(t, "*"))) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return ErrTypeNotAllowed
+		}
+	}
+
+	expected := extensionTypes[strings.ToLower(path.Ext(name))]
+	if expected == "" || expected == detected {
+		return nil
+	}
+
+	if strings.HasPrefix(expected, "text/") && strings.HasPrefix(detected, "text/") {
+		return nil
+	}
+
+	// contents which cannot be told apart from plain text or binary only mismatch an
+	// extension whose type can always be detected.
+	if (detected == "text/plain" || detected == "application/octet-stream") && !detectable(expected) {
+		return nil
+	}
+
+	return ErrTypeMismatch
+}
+
+// mediaTypeAliases maps the types returned for some extensions to the type detected from their contents.
+var mediaTypeAliases = map[string]string{
+	"image/vnd.microsoft.icon": "image/x-icon",
+	"audio/wav":                "audio/wave",
+	"audio/x-wav":              "audio/wave",
+	"application/gzip":         "application/x-gzip",
+}
+
+// mediaType strips the parameters from a mime type and normalises it.
+func mediaType(t string) string {
+	if t == "" {
+		return ""
+	}
+
+	mt, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return strings.ToLower(t)
+	}
+
+	if alias, ok := mediaTypeAliases[mt]; ok {
+		return alias
+	}
+
+	return mt
+}
+
+// detectable reports whether files of a type always start with magic bytes that
+// DetectContentType recognises.
+func detectable(t string) bool {
+	switch {
+	case t == "image/svg+xml":
+		return false
+	case strings.HasPrefix(t, "image/"), strings.HasPrefix(t, "audio/"), strings.HasPrefix(t, "video/"):
+		return true
+	}
+
+	switch t {
+	case "application/pdf", "application/zip", "application/x-gzip", "application/wasm":
+		return true
+	}
+
+	return false
+}
+
+// ValidatingFileSystem checks every file put to a wrapped file system against a
+// ValidationPolicy, files which fail are not written.
+type ValidatingFileSystem struct {
+	fs     FileSystem
+	policy ValidationPolicy
+}
+
+// NewValidatingFileSystem is a construct function which checks files put to fs against policy.
+func NewValidatingFileSystem(fs FileSystem, policy ValidationPolicy) *ValidatingFileSystem {
+	return &ValidatingFileSystem{
+		fs,
+		policy,
+	}
+}
+
+// Put validates the file and writes it to the wrapped file system, the size is also
+// enforced as src is streamed.
+func (v *ValidatingFileSystem) Put(src io.ReadSeeker, path string) (File, error) {
+	return v.PutWithOptions(src, path, PutOptions{})
+}
+
+// PutWithOptions validates the file and writes it with the given options.
+func (v *ValidatingFileSystem) PutWithOptions(src io.ReadSeeker, path string, opts PutOptions) (File, error) {
+	if err := v.policy.Validate(src, path); err != nil {
+		return nil, err
+	}
+
+	if v.policy.MaxSize > 0 {
+		start, err := src.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		src = &sizeLimitedReader{src, start, start + v.policy.MaxSize}
+	}
+
+	file, err := PutWithOptions(v.fs, src, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidatedFile{file, path, v}, nil
+}
+
+// Get returns the file from the wrapped file system.
+func (v *ValidatingFileSystem) Get(path string) (File, error) {
+	file, err := v.fs.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidatedFile{file, path, v}, nil
+}
+
+// Delete removes the file from the wrapped file system.
+func (v *ValidatingFileSystem) Delete(path string) error {
+	return Delete(v.fs, path)
+}
+
+// List lists the files of the wrapped file system starting with prefix.
+func (v *ValidatingFileSystem) List(prefix string) ([]string, error) {
+	return List(v.fs, prefix)
+}
+
+// ValidatedFile is a File held by a ValidatingFileSystem.
+type ValidatedFile struct {
+	File
+	path string
+	fs   *ValidatingFileSystem
+}
+
+// unwrap returns the wrapped file.
+func (f *ValidatedFile) unwrap() File {
+	return f.File
+}
+
+// Write replaces the contents of the file by putting p to the validating file system,
+// so the new contents are validated.
+func (f *ValidatedFile) Write(p []byte) (n int, err error) {
+	_, err = f.fs.Put(bytes.NewReader(p), f.path)
+	return len(p), err
+}
+
+// sizeLimitedReader fails with ErrTooLarge once it is read past its limit.
+type sizeLimitedReader struct {
+	io.ReadSeeker
+	pos   int64
+	limit int64
+}
+
+// Read reads from the source, failing with ErrTooLarge if the source holds more than the limit.
+func (r *sizeLimitedReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadSeeker.Read(p)
+	r.pos += int64(n)
+	if r.pos > r.limit {
+		return n, ErrTooLarge
+	}
+
+	return n, err
+}
+
+// Seek seeks the source and tracks the position.
+func (r *sizeLimitedReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+
+	return pos, err
+}
@@ -0,0 +1,92 @@
+package gofile
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestValidatingFileSystemAcceptsAllowedFiles(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewValidatingFileSystem(mem, ValidationPolicy{
+		MaxSize:      100,
+		AllowedTypes: []string{"image/*"},
+	})
+
+	_, err := fs.Put(bytes.NewReader(pngHeader), "avatar.png")
+	assert.Nil(t, err)
+
+	assert.Equal(t, string(pngHeader), readAll(t, mem, "avatar.png"))
+}
+
+func TestValidatingFileSystemRejectsMismatchedContents(t *testing.T) {
+	mem := NewMemoryFileSystem()
+	fs := NewValidatingFileSystem(mem, ValidationPolicy{})
+
+	_, err := fs.Put(bytes.NewReader([]byte("<html><script>alert(1)</script></html>")), "avatar.png")
+	assert.Equal(t, ErrTypeMismatch, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("just some text")), "avatar.png")
+	assert.Equal(t, ErrTypeMismatch, err)
+
+	_, err = mem.Get("avatar.png")
+	assert.True(t, IsNotExist(err))
+
+	_, err = fs.Put(bytes.NewReader([]byte(`{"key": "value"}`)), "data.json")
+	assert.Nil(t, err)
+}
+
+func TestValidatingFileSystemTreatsTextTypesAsCompatible(t *testing.T) {
+	fs := NewValidatingFileSystem(NewMemoryFileSystem(), ValidationPolicy{})
+
+	_, err := fs.Put(bytes.NewReader([]byte("<html><body>notes</body></html>")), "notes.txt")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("just some text")), "page.html")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")), "report.doc")
+	assert.Nil(t, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("<html></html>")), "archive.zip")
+	assert.Equal(t, ErrTypeMismatch, err)
+}
+
+func TestValidatingFileSystemRejectsTypesNotAllowed(t *testing.T) {
+	fs := NewValidatingFileSystem(NewMemoryFileSystem(), ValidationPolicy{
+		AllowedTypes:      []string{"image/png", "image/jpeg"},
+		AllowedExtensions: []string{".png", ".jpg"},
+	})
+
+	_, err := fs.Put(bytes.NewReader([]byte("GIF89a")), "image.gif")
+	assert.Equal(t, ErrTypeNotAllowed, err)
+
+	_, err = fs.Put(bytes.NewReader([]byte("GIF89a")), "image.png")
+	assert.Equal(t, ErrTypeNotAllowed, err)
+
+	_, err = fs.Put(bytes.NewReader(pngHeader), "image.PNG")
+	assert.Nil(t, err)
+}
+
+func TestValidatingFileSystemEnforcesMaxSize(t *testing.T) {
+	fs := NewValidatingFileSystem(NewMemoryFileSystem(), ValidationPolicy{MaxSize: 10})
+
+	src := bytes.NewReader([]byte("more than ten bytes"))
+	_, err := fs.Put(src, "file.txt")
+	assert.Equal(t, ErrTooLarge, err)
+
+	pos, _ := src.Seek(0, io.SeekCurrent)
+	assert.Equal(t, int64(0), pos)
+}
+
+func TestSizeLimitedReaderFailsPastLimit(t *testing.T) {
+	r := &sizeLimitedReader{bytes.NewReader([]byte("12345")), 0, 3}
+
+	_, err := io.Copy(ioutil.Discard, r)
+	assert.Equal(t, ErrTooLarge, err)
+}